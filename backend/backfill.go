@@ -0,0 +1,388 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JohnCrickett/top-stories/keywords"
+)
+
+const (
+	backfillBatchSize       = 100
+	defaultBackfillRate     = 10
+	defaultBackfillProgress = "backfill.checkpoint.json"
+
+	// Items that fail to fetch are retried this many times, with a doubling
+	// delay, before the backfill stops at their batch
+	backfillFetchRetries = 3
+)
+
+// backfillFetchRetryDelay is the delay before the first retry; tests shorten it
+var backfillFetchRetryDelay = 2 * time.Second
+
+// backfillOptions selects which historical items are published
+type backfillOptions struct {
+	from, to       int   // inclusive ID range, walked downward
+	since, until   int64 // unix time range (0 = unbounded)
+	types          map[string]bool
+	keywords       []*regexp.Regexp
+	minScore       int
+	checkpointPath string
+}
+
+// backfillCheckpoint is persisted after every batch so a run can be resumed.
+// When a batch fails part way, BatchPublished holds the stories of the batch
+// at NextID that were already delivered, for the resume to skip.
+type backfillCheckpoint struct {
+	From           int   `json:"from"`
+	To             int   `json:"to"`
+	NextID         int   `json:"next_id"`
+	Scanned        int   `json:"scanned"`
+	Published      int   `json:"published"`
+	BatchPublished []int `json:"batch_published,omitempty"`
+	UpdatedAt      int64 `json:"updated_at"`
+}
+
+// runBackfill implements the "backfill" command: it walks HN item IDs
+// downward and publishes matching stories through the normal publish path.
+// Stories in the scraper's seen store are skipped, but the store is never
+// written: a scraper running alongside owns that file.
+func runBackfill(args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	from := fs.Int("from", 0, "Highest item ID to scan (default: current maxitem)")
+	to := fs.Int("to", 1, "Lowest item ID to scan")
+	since := fs.String("since", "", "Stop at items older than this RFC3339 time")
+	until := fs.String("until", "", "Skip items newer than this RFC3339 time")
+	types := fs.String("types", "story", "Comma-separated item types to publish (empty = all)")
+	keywordList := fs.String("keywords", "", "Comma-separated title keywords, matched like story-api keywords; publish if ANY matches")
+	minScore := fs.Int("min-score", 0, "Minimum score to publish")
+	rate := fs.Float64("rate", defaultBackfillRate, "Maximum requests per second to the HN API")
+	checkpoint := fs.String("checkpoint", defaultBackfillProgress, "Checkpoint file for resuming")
+	fs.Parse(args)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	cfg.Scraper.RequestsPerSecond = *rate
+
+	opts := backfillOptions{
+		from:           *from,
+		to:             *to,
+		types:          make(map[string]bool),
+		minScore:       *minScore,
+		checkpointPath: *checkpoint,
+	}
+	if *since != "" {
+		t, err := time.Parse(time.RFC3339, *since)
+		if err != nil {
+			return fmt.Errorf("invalid -since: %w", err)
+		}
+		opts.since = t.Unix()
+	}
+	if *until != "" {
+		t, err := time.Parse(time.RFC3339, *until)
+		if err != nil {
+			return fmt.Errorf("invalid -until: %w", err)
+		}
+		opts.until = t.Unix()
+	}
+	for _, t := range splitList(*types) {
+		opts.types[t] = true
+	}
+	opts.keywords, err = keywords.Compile(splitList(*keywordList))
+	if err != nil {
+		return fmt.Errorf("invalid -keywords: %w", err)
+	}
+
+	scraper, err := NewScraper(*cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize scraper: %w", err)
+	}
+	handleSignals(scraper)
+	defer scraper.closePublisher()
+
+	return scraper.backfill(opts)
+}
+
+func (s *Scraper) backfill(opts backfillOptions) error {
+	cp, err := loadBackfillCheckpoint(opts.checkpointPath)
+	if err != nil {
+		return err
+	}
+
+	if cp != nil {
+		// -from 0 means maxitem, which the checkpoint has already resolved
+		if (opts.from != 0 && opts.from != cp.From) || max(opts.to, 1) != cp.To {
+			return fmt.Errorf("checkpoint %s is for items %d down to %d; rerun with -from %d -to %d to resume it, or delete it to start over",
+				opts.checkpointPath, cp.From, cp.To, cp.From, cp.To)
+		}
+		fmt.Printf("[BACKFILL] Resuming from checkpoint: next ID %d (scanned %d, published %d)\n",
+			cp.NextID, cp.Scanned, cp.Published)
+	} else {
+		if opts.from == 0 {
+			if err := s.getJSON(maxItemURL, &opts.from); err != nil {
+				return fmt.Errorf("failed to fetch max item: %w", err)
+			}
+		}
+		cp = &backfillCheckpoint{From: opts.from, To: max(opts.to, 1), NextID: opts.from}
+	}
+
+	total := cp.From - cp.To + 1
+	start := time.Now()
+	startScanned := cp.Scanned
+	fmt.Printf("[BACKFILL] Scanning items %d down to %d\n", cp.NextID, cp.To)
+
+	for cp.NextID >= cp.To {
+		if s.ctx.Err() != nil {
+			return saveBackfillCheckpoint(opts.checkpointPath, cp)
+		}
+
+		// Next batch of IDs, highest first
+		ids := make([]int, 0, backfillBatchSize)
+		for id := cp.NextID; id >= cp.To && len(ids) < backfillBatchSize; id-- {
+			ids = append(ids, id)
+		}
+
+		stories, failed := s.fetchBatch(ids)
+		if s.ctx.Err() != nil {
+			// The batch may be incomplete; redo it on resume
+			return saveBackfillCheckpoint(opts.checkpointPath, cp)
+		}
+
+		delivered := make(map[int]bool, len(cp.BatchPublished))
+		for _, id := range cp.BatchPublished {
+			delivered[id] = true
+		}
+
+		reachedSince := false
+		var matched []*Story
+		for _, story := range stories {
+			if story == nil {
+				continue
+			}
+			if opts.since > 0 && story.Time > 0 && story.Time < opts.since {
+				reachedSince = true
+				continue
+			}
+			if !opts.matches(story) {
+				continue
+			}
+			if delivered[story.ID] {
+				continue
+			}
+			if _, seen := s.seenStories.Get(story.ID); seen {
+				continue
+			}
+			matched = append(matched, story)
+		}
+
+		published, err := s.publishAndWait(matched)
+		cp.Published += len(published)
+		if err == nil && failed > 0 {
+			err = fmt.Errorf("failed to fetch %d items between %d and %d", failed, ids[len(ids)-1], ids[0])
+		}
+		if err != nil {
+			// Leave the checkpoint at this batch so a resume retries it,
+			// skipping the stories that were delivered
+			cp.BatchPublished = append(cp.BatchPublished, published...)
+			if saveErr := saveBackfillCheckpoint(opts.checkpointPath, cp); saveErr != nil || s.ctx.Err() != nil {
+				return saveErr
+			}
+			return err
+		}
+
+		cp.Scanned += len(ids)
+		cp.NextID = ids[len(ids)-1] - 1
+		cp.BatchPublished = nil
+		if err := saveBackfillCheckpoint(opts.checkpointPath, cp); err != nil {
+			return err
+		}
+		reportBackfillProgress(cp, total, cp.Scanned-startScanned, start)
+
+		// IDs are assigned in time order, so nothing further down is in range
+		if reachedSince {
+			fmt.Println("[BACKFILL] Reached -since time")
+			break
+		}
+	}
+
+	fmt.Printf("[BACKFILL] Done: scanned %d items, published %d stories\n", cp.Scanned, cp.Published)
+	if err := os.Remove(opts.checkpointPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove checkpoint: %w", err)
+	}
+	return nil
+}
+
+// fetchBatch fetches a batch of items, retrying the ones that fail. It returns
+// the items in the order of ids and how many still failed after the retries.
+func (s *Scraper) fetchBatch(ids []int) ([]*Story, int) {
+	stories := s.fetchStories(ids)
+	delay := backfillFetchRetryDelay
+	for retry := 1; ; retry++ {
+		var failed []int
+		for i, story := range stories {
+			if story == nil {
+				failed = append(failed, i)
+			}
+		}
+		if len(failed) == 0 || retry > backfillFetchRetries || s.ctx.Err() != nil {
+			return stories, len(failed)
+		}
+
+		fmt.Printf("[BACKFILL] Failed to fetch %d items, retrying in %v (retry %d of %d)\n",
+			len(failed), delay, retry, backfillFetchRetries)
+		select {
+		case <-time.After(delay):
+		case <-s.ctx.Done():
+			return stories, len(failed)
+		}
+		delay *= 2
+
+		retryIDs := make([]int, len(failed))
+		for j, i := range failed {
+			retryIDs[j] = ids[i]
+		}
+		for j, story := range s.fetchStories(retryIDs) {
+			stories[failed[j]] = story
+		}
+	}
+}
+
+// publishAndWait publishes stories as one batch and waits until the publisher
+// has settled all of them. It returns the IDs of the stories that were
+// delivered and the first error, if any.
+func (s *Scraper) publishAndWait(stories []*Story) ([]int, error) {
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		published []int
+		firstErr  error
+	)
+
+	fetchedAt := time.Now()
+	for _, story := range stories {
+		wg.Add(1)
+		req := publishRequest{story: story, source: "backfill", feeds: []string{"backfill"}, event: eventCreated, fetchedAt: fetchedAt}
+		s.publishStory(req, func(err error) {
+			defer wg.Done()
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			published = append(published, story.ID)
+		})
+	}
+	wg.Wait()
+
+	return published, firstErr
+}
+
+// matches applies the type, time, score and keyword criteria to a story
+func (o backfillOptions) matches(story *Story) bool {
+	if story.Title == "" {
+		return false // deleted/dead or a comment
+	}
+	if len(o.types) > 0 && !o.types[story.Type] {
+		return false
+	}
+	if o.until > 0 && story.Time > o.until {
+		return false
+	}
+	if story.Score < o.minScore {
+		return false
+	}
+	if len(o.keywords) == 0 {
+		return true
+	}
+	for _, k := range o.keywords {
+		if k.MatchString(story.Title) {
+			return true
+		}
+	}
+	return false
+}
+
+func reportBackfillProgress(cp *backfillCheckpoint, total, scannedThisRun int, start time.Time) {
+	elapsed := time.Since(start).Seconds()
+	rate := float64(scannedThisRun) / elapsed
+	remaining := cp.NextID - cp.To + 1
+	eta := "unknown"
+	if rate > 0 {
+		eta = (time.Duration(float64(remaining)/rate) * time.Second).Round(time.Second).String()
+	}
+	fmt.Printf("[BACKFILL] %d/%d items scanned (%.1f%%), %d published, next ID %d, %.1f items/s, ETA %s\n",
+		cp.Scanned, total, 100*float64(cp.Scanned)/float64(total), cp.Published, cp.NextID, rate, eta)
+}
+
+func loadBackfillCheckpoint(path string) (*backfillCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var cp backfillCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	return &cp, nil
+}
+
+// saveBackfillCheckpoint writes the checkpoint to a temp file and renames it
+// into place, so a crash never leaves a half-written checkpoint behind
+func saveBackfillCheckpoint(path string, cp *backfillCheckpoint) error {
+	cp.UpdatedAt = time.Now().Unix()
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".checkpoint-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace checkpoint: %w", err)
+	}
+	return nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JohnCrickett/top-stories/keywords"
+)
+
+func TestBackfillOptionsKeywords(t *testing.T) {
+	compiled, err := keywords.Compile([]string{"rust", "c++", "/^show hn:/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := backfillOptions{keywords: compiled}
+
+	tests := []struct {
+		title string
+		want  bool
+	}{
+		{"Rust 1.80 released", true},
+		{"Building trust in open source", false},
+		{"C++ 26 is out", true},
+		{"C is great", false},
+		{"Show HN: A tiny database", true},
+		{"Why I don't show HN my projects", false},
+	}
+	for _, tt := range tests {
+		if got := opts.matches(&Story{Title: tt.title}); got != tt.want {
+			t.Errorf("matches(%q) = %v, want %v", tt.title, got, tt.want)
+		}
+	}
+}
+
+// hnItems serves /v0/item/<id>.json for stories 1 to n. failures[id] is how
+// many more requests for the item fail with a 503; -1 fails them all.
+type hnItems struct {
+	mu       sync.Mutex
+	n        int
+	failures map[int]int
+}
+
+func (h *hnItems) RoundTrip(r *http.Request) (*http.Response, error) {
+	var id int
+	fmt.Sscanf(r.URL.Path, "/v0/item/%d.json", &id)
+
+	h.mu.Lock()
+	fail := h.failures[id]
+	if fail > 0 {
+		h.failures[id]--
+	}
+	h.mu.Unlock()
+
+	status, body := http.StatusOK, "null"
+	if fail != 0 {
+		status = http.StatusServiceUnavailable
+	} else if id >= 1 && id <= h.n {
+		body = fmt.Sprintf(`{"id":%d,"title":"Story %d","type":"story","time":%d}`, id, id, 1700000000+id)
+	}
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body)), Request: r}, nil
+}
+
+func newBackfillScraper(t *testing.T, items *hnItems, publisher Publisher, seenPath string) *Scraper {
+	t.Helper()
+	seen, err := NewSeenStore(seenPath, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Scraper{
+		client:        &http.Client{Transport: items},
+		limiter:       newHostLimiter(0),
+		seenStories:   seen,
+		publisher:     publisher,
+		schemaVersion: currentSchemaVersion,
+		workers:       4,
+		fetchSlots:    make(chan struct{}, 4),
+		ctx:           context.Background(),
+	}
+}
+
+func TestBackfillRetriesFailedFetches(t *testing.T) {
+	backfillFetchRetryDelay = time.Millisecond
+	items := &hnItems{n: 5, failures: map[int]int{2: 2, 4: -1}}
+	publisher := &fakePublisher{}
+	seenPath := filepath.Join(t.TempDir(), "seen_stories.json")
+	opts := backfillOptions{
+		from:           5,
+		to:             1,
+		types:          map[string]bool{"story": true},
+		checkpointPath: filepath.Join(t.TempDir(), "checkpoint.json"),
+	}
+
+	// Item 2 recovers within the retries; item 4 doesn't, so the backfill
+	// stops at the batch instead of skipping it
+	if err := newBackfillScraper(t, items, publisher, seenPath).backfill(opts); err == nil {
+		t.Fatal("backfill succeeded with an item that never fetched")
+	}
+	cp, err := loadBackfillCheckpoint(opts.checkpointPath)
+	if err != nil || cp == nil {
+		t.Fatalf("checkpoint: %v, %v", cp, err)
+	}
+	if cp.NextID != 5 || fmt.Sprint(cp.BatchPublished) != "[5 3 2 1]" {
+		t.Errorf("checkpoint at %d with %v published, want 5 with [5 3 2 1]", cp.NextID, cp.BatchPublished)
+	}
+	if got := strings.Join(publisher.published(), ","); got != "5,3,2,1" {
+		t.Errorf("published %s, want 5,3,2,1", got)
+	}
+
+	// Once the item can be fetched a fresh run resumes and publishes it, and
+	// only it
+	items.failures[4] = 0
+	if err := newBackfillScraper(t, items, publisher, seenPath).backfill(opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(publisher.published(), ","); got != "5,3,2,1,4" {
+		t.Errorf("published %s after resuming, want 5,3,2,1,4", got)
+	}
+	if _, err := os.Stat(opts.checkpointPath); !os.IsNotExist(err) {
+		t.Errorf("checkpoint left behind after the backfill completed: %v", err)
+	}
+
+	// The scraper's seen store belongs to the scraper
+	if _, err := os.Stat(seenPath); !os.IsNotExist(err) {
+		t.Errorf("backfill wrote the seen store: %v", err)
+	}
+}
+
+func TestBackfillCheckpointRange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	if err := saveBackfillCheckpoint(path, &backfillCheckpoint{From: 500, To: 100, NextID: 300}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		from, to int
+		ok       bool
+	}{
+		{500, 100, true},
+		{0, 100, true}, // -from defaults to maxitem, resolved by the checkpoint
+		{600, 100, false},
+		{500, 1, false},
+	}
+	for _, tt := range tests {
+		items := &hnItems{n: 0}
+		s := newBackfillScraper(t, items, &fakePublisher{}, "")
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel() // stop before the first batch
+		s.ctx = ctx
+
+		err := s.backfill(backfillOptions{from: tt.from, to: tt.to, checkpointPath: path})
+		if (err == nil) != tt.ok {
+			t.Errorf("-from %d -to %d: err = %v, want ok = %v", tt.from, tt.to, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message schema versions. Version 1 is a bare Story; version 2 wraps it in an
+// Envelope. Consumers accept both, so producers can be switched independently.
+const (
+	schemaVersionLegacy   = 1
+	schemaVersionEnvelope = 2
+	currentSchemaVersion  = schemaVersionEnvelope
+)
+
+// Envelope is the version 2 message format. The same metadata is also sent as
+// Kafka headers so consumers can route messages without decoding the value.
+type Envelope struct {
+	SchemaVersion int       `json:"schema_version"`
+	Event         string    `json:"event"`           // created, updated or deleted
+	Source        string    `json:"source"`          // feed or source name, e.g. "top" or "lobsters"
+	Feeds         []string  `json:"feeds,omitempty"` // every feed the story has appeared in, sorted
+	FetchedAt     time.Time `json:"fetched_at"`
+	Payload       *Story    `json:"payload"`
+}
+
+// encodeMessage builds the message for a publish request in the given schema
+// version. The key is the story ID, so every event for a story lands on the
+// same partition and is consumed in order.
+func encodeMessage(req publishRequest, version int) (Message, error) {
+	var value any
+	switch version {
+	case schemaVersionLegacy:
+		value = req.story
+	case schemaVersionEnvelope:
+		value = Envelope{
+			SchemaVersion: version,
+			Event:         req.event,
+			Source:        req.source,
+			Feeds:         req.feeds,
+			FetchedAt:     req.fetchedAt.UTC(),
+			Payload:       req.story,
+		}
+	default:
+		return Message{}, fmt.Errorf("unsupported schema version %d", version)
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to marshal story: %w", err)
+	}
+
+	return Message{
+		Key:   strconv.Itoa(req.story.ID),
+		Value: data,
+		Headers: map[string]string{
+			"schema_version": strconv.Itoa(version),
+			"event":          req.event,
+			"source":         req.source,
+			"feeds":          strings.Join(req.feeds, ","),
+			"fetched_at":     req.fetchedAt.UTC().Format(time.RFC3339),
+		},
+	}, nil
+}
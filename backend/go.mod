@@ -3,6 +3,7 @@ module github.com/JohnCrickett/top-stories/backend
 go 1.23
 
 require (
+	github.com/JohnCrickett/top-stories/keywords v0.0.0
 	github.com/segmentio/kafka-go v0.4.47
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -10,4 +11,10 @@ require (
 require (
 	github.com/klauspost/compress v1.15.9 // indirect
 	github.com/pierrec/lz4/v4 v4.1.15 // indirect
+	github.com/xdg-go/pbkdf2 v1.0.0 // indirect
+	github.com/xdg-go/scram v1.1.2 // indirect
+	github.com/xdg-go/stringprep v1.0.4 // indirect
+	golang.org/x/text v0.13.0 // indirect
 )
+
+replace github.com/JohnCrickett/top-stories/keywords => ../keywords
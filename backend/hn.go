@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	hnSite = "news.ycombinator.com"
+
+	defaultFullRefreshMinutes = 10
+)
+
+// IncrementalConfig enables change detection for Hacker News feeds using
+// /v0/updates.json (recently changed items) and /v0/maxitem.json (newest item
+// ID). Only items that are new, changed or older than the full refresh
+// interval are re-downloaded; the rest are served from the previous poll.
+type IncrementalConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// FullRefreshMinutes re-fetches every item at least this often, covering
+	// changes that fell outside the updates window (default 10)
+	FullRefreshMinutes int `yaml:"full_refresh_minutes"`
+}
+
+// FeedConfig selects one Hacker News feed. Zero values fall back to the
+// scraper-wide stories_to_fetch and poll_interval_seconds.
+type FeedConfig struct {
+	Name                string `yaml:"name"` // top, new, best, ask, show or job
+	StoriesToFetch      int    `yaml:"stories_to_fetch"`
+	PollIntervalSeconds int    `yaml:"poll_interval_seconds"`
+}
+
+// hnFeeds are the story lists exposed by the Hacker News API
+var hnFeeds = map[string]bool{
+	"top":  true,
+	"new":  true,
+	"best": true,
+	"ask":  true,
+	"show": true,
+	"job":  true,
+}
+
+// hnSource polls one Hacker News feed. Its name is the bare feed name ("top",
+// "new", ...) so existing consumers keep seeing the same source names.
+type hnSource struct {
+	sourceInfo
+	scraper *Scraper
+	url     string
+
+	// Incremental mode state, only touched by the source's polling goroutine
+	incremental bool
+	fullRefresh time.Duration
+	cache       map[int]cachedItem
+	maxItem     int
+}
+
+// cachedItem is an item fetched on an earlier poll
+type cachedItem struct {
+	story     *Story
+	fetchedAt time.Time
+}
+
+// hnUpdates is the body of /v0/updates.json
+type hnUpdates struct {
+	Items    []int    `json:"items"`
+	Profiles []string `json:"profiles"`
+}
+
+// newHNSources validates the configured feeds, defaulting to top and new when
+// none are listed. An explicitly empty list (feeds: []) disables HN polling.
+func newHNSources(s *Scraper, cfg ScraperConfig) ([]Source, error) {
+	feedCfgs := cfg.Feeds
+	if feedCfgs == nil {
+		feedCfgs = []FeedConfig{{Name: "top"}, {Name: "new"}}
+	}
+
+	sources := make([]Source, 0, len(feedCfgs))
+	for _, fc := range feedCfgs {
+		if !hnFeeds[fc.Name] {
+			return nil, fmt.Errorf("unknown feed %q (want top, new, best, ask, show or job)", fc.Name)
+		}
+		fullRefresh := cfg.Incremental.FullRefreshMinutes
+		if fullRefresh <= 0 {
+			fullRefresh = defaultFullRefreshMinutes
+		}
+		sources = append(sources, &hnSource{
+			sourceInfo:  newSourceInfo(fc.Name, fc.StoriesToFetch, fc.PollIntervalSeconds, cfg),
+			scraper:     s,
+			url:         fmt.Sprintf(feedURL, fc.Name),
+			incremental: cfg.Incremental.Enabled,
+			fullRefresh: time.Duration(fullRefresh) * time.Minute,
+			cache:       make(map[int]cachedItem),
+		})
+	}
+	return sources, nil
+}
+
+// Fetch fetches the top N items of the feed using the worker pool
+func (h *hnSource) Fetch() ([]*Story, error) {
+	ids, err := h.scraper.fetchStoryIDs(h.url)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only fetch the top N
+	if len(ids) > h.storiesToFetch {
+		ids = ids[:h.storiesToFetch]
+	}
+
+	if !h.incremental {
+		return h.scraper.fetchStories(ids), nil
+	}
+	return h.fetchChanged(ids), nil
+}
+
+// fetchChanged fetches only the items in ids that are new, reported as
+// changed, or due for a full refresh, and reuses the cached copy of the rest
+func (h *hnSource) fetchChanged(ids []int) []*Story {
+	changed, maxItem, err := h.changes()
+	if err != nil {
+		// Without change information every item has to be fetched
+		fmt.Printf("[INCREMENTAL] %s: %v, fetching all items\n", h.name, err)
+		h.cache = make(map[int]cachedItem)
+	}
+
+	now := time.Now()
+	var stale []int
+	for _, id := range ids {
+		cached, ok := h.cache[id]
+		if !ok || changed[id] || id > h.maxItem || now.Sub(cached.fetchedAt) >= h.fullRefresh {
+			stale = append(stale, id)
+		}
+	}
+
+	fetched := make(map[int]*Story, len(stale))
+	for i, story := range h.scraper.fetchStories(stale) {
+		if story != nil {
+			fetched[stale[i]] = story
+		}
+	}
+
+	// Keep the cache limited to the current list
+	cache := make(map[int]cachedItem, len(ids))
+	stories := make([]*Story, len(ids))
+	for i, id := range ids {
+		if story, ok := fetched[id]; ok {
+			cache[id] = cachedItem{story: story, fetchedAt: now}
+		} else if cached, ok := h.cache[id]; ok {
+			cache[id] = cached
+		}
+		stories[i] = cache[id].story
+	}
+	h.cache = cache
+	if maxItem > h.maxItem {
+		h.maxItem = maxItem
+	}
+
+	fmt.Printf("[INCREMENTAL] %s: fetched %d of %d items\n", h.name, len(stale), len(ids))
+	return stories
+}
+
+// changes returns the recently changed item IDs and the current max item ID
+func (h *hnSource) changes() (map[int]bool, int, error) {
+	var updates hnUpdates
+	if err := h.scraper.getJSON(updatesURL, &updates); err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch updates: %w", err)
+	}
+
+	var maxItem int
+	if err := h.scraper.getJSON(maxItemURL, &maxItem); err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch max item: %w", err)
+	}
+
+	changed := make(map[int]bool, len(updates.Items))
+	for _, id := range updates.Items {
+		changed[id] = true
+	}
+	return changed, maxItem, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// SASLConfig holds SASL credentials for the sasl_* security protocols
+type SASLConfig struct {
+	Mechanism string `yaml:"mechanism"` // plain, scram-sha-256 or scram-sha-512
+	Username  string `yaml:"username"`
+	Password  string `yaml:"password"`
+}
+
+// createKafkaDialer builds a dialer for the configured security protocol:
+//   - plaintext: no TLS, no authentication (local brokers)
+//   - ssl (default): TLS, with a client certificate if one is configured
+//   - sasl_plaintext / sasl_ssl: SASL authentication without / with TLS
+func createKafkaDialer(cfg KafkaConfig) (*kafka.Dialer, error) {
+	dialer := &kafka.Dialer{
+		Timeout:   10 * time.Second,
+		DualStack: true,
+	}
+
+	protocol := strings.ToLower(cfg.SecurityProtocol)
+	if protocol == "" {
+		protocol = "ssl"
+	}
+
+	switch protocol {
+	case "plaintext", "sasl_plaintext":
+	case "ssl", "sasl_ssl":
+		tlsConfig, err := createTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		dialer.TLS = tlsConfig
+	default:
+		return nil, fmt.Errorf("unknown security protocol %q (want plaintext, ssl, sasl_plaintext or sasl_ssl)",
+			cfg.SecurityProtocol)
+	}
+
+	if strings.HasPrefix(protocol, "sasl_") {
+		mechanism, err := createSASLMechanism(cfg.SASL)
+		if err != nil {
+			return nil, err
+		}
+		dialer.SASLMechanism = mechanism
+	}
+
+	return dialer, nil
+}
+
+// createTLSConfig trusts the configured CA (or the system roots if none is
+// set) and presents a client certificate when both cert and key are set
+func createTLSConfig(cfg KafkaConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if cfg.CACertPath != "" {
+		caCert, err := os.ReadFile(cfg.CACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA cert: %w", err)
+		}
+
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("failed to parse CA cert")
+		}
+		tlsConfig.RootCAs = caCertPool
+	}
+
+	if (cfg.ClientCertPath == "") != (cfg.ClientKeyPath == "") {
+		return nil, fmt.Errorf("client_cert_path and client_key_path must be set together")
+	}
+	if cfg.ClientCertPath != "" {
+		keypair, err := tls.LoadX509KeyPair(cfg.ClientCertPath, cfg.ClientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client cert/key: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{keypair}
+	}
+
+	return tlsConfig, nil
+}
+
+func createSASLMechanism(cfg SASLConfig) (sasl.Mechanism, error) {
+	if cfg.Username == "" {
+		return nil, fmt.Errorf("SASL requires a username")
+	}
+
+	switch strings.ToLower(cfg.Mechanism) {
+	case "plain":
+		return plain.Mechanism{Username: cfg.Username, Password: cfg.Password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, cfg.Username, cfg.Password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, cfg.Username, cfg.Password)
+	default:
+		return nil, fmt.Errorf("unknown SASL mechanism %q (want plain, scram-sha-256 or scram-sha-512)", cfg.Mechanism)
+	}
+}
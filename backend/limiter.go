@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// hostLimiter spaces out requests to each host so that no host receives more
+// than the configured number of requests per second. A zero rate disables it.
+type hostLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     map[string]time.Time // host -> earliest time the next request may start
+}
+
+func newHostLimiter(requestsPerSecond float64) *hostLimiter {
+	l := &hostLimiter{next: make(map[string]time.Time)}
+	if requestsPerSecond > 0 {
+		l.interval = time.Duration(float64(time.Second) / requestsPerSecond)
+	}
+	return l
+}
+
+// wait blocks until a request to host is allowed or ctx is cancelled
+func (l *hostLimiter) wait(ctx context.Context, host string) error {
+	if l.interval == 0 {
+		return ctx.Err()
+	}
+
+	// Reserve the next free slot for this host
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next[host] = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -2,46 +2,104 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
-	"github.com/segmentio/kafka-go"
 	"gopkg.in/yaml.v3"
 )
 
 const (
-	baseURL       = "https://hacker-news.firebaseio.com/v0"
-	topStoriesURL = baseURL + "/topstories.json"
-	newStoriesURL = baseURL + "/newstories.json"
-	itemURL       = baseURL + "/item/%d.json"
+	userAgent = "top-stories-scraper/1.0 (+https://github.com/JohnCrickett/top-stories)"
+
+	baseURL    = "https://hacker-news.firebaseio.com/v0"
+	feedURL    = baseURL + "/%sstories.json"
+	itemURL    = baseURL + "/item/%d.json"
+	updatesURL = baseURL + "/updates.json"
+	maxItemURL = baseURL + "/maxitem.json"
+
+	defaultPollIntervalSeconds = 60
+	defaultStoriesToFetch      = 30
+
+	defaultWorkers = 8
+
+	defaultUpdateScoreDelta    = 10
+	defaultUpdateCommentsDelta = 10
+
+	eventCreated = "created"
+	eventUpdated = "updated"
+	eventDeleted = "deleted"
+
+	defaultKafkaBatchSize   = 100
+	defaultKafkaLingerMs    = 100
+	defaultKafkaMaxAttempts = 5
+
+	// publishQueueSize bounds how many deduplicated stories can wait to be published
+	publishQueueSize = 1000
 )
 
 type Config struct {
-	Kafka  KafkaConfig  `yaml:"kafka"`
-	Scraper ScraperConfig `yaml:"scraper"`
+	Kafka     KafkaConfig     `yaml:"kafka"`
+	Scraper   ScraperConfig   `yaml:"scraper"`
+	Publisher PublisherConfig `yaml:"publisher"`
+	Metrics   MetricsConfig   `yaml:"metrics"`
 }
 
 type KafkaConfig struct {
-	Broker        string `yaml:"broker"`
-	Topic         string `yaml:"topic"`
-	CACertPath    string `yaml:"ca_cert_path"`
+	Broker         string `yaml:"broker"`
+	Topic          string `yaml:"topic"`
+	CACertPath     string `yaml:"ca_cert_path"`
 	ClientCertPath string `yaml:"client_cert_path"`
-	ClientKeyPath string `yaml:"client_key_path"`
+	ClientKeyPath  string `yaml:"client_key_path"`
+	// SecurityProtocol is plaintext, ssl (default), sasl_plaintext or sasl_ssl
+	SecurityProtocol string     `yaml:"security_protocol"`
+	SASL             SASLConfig `yaml:"sasl"`
+	// Writer batching: messages per batch (default 100), how long to wait
+	// for a batch to fill (default 100ms), codec (none, gzip, snappy, lz4 or
+	// zstd) and delivery attempts per batch (default 5)
+	BatchSize   int    `yaml:"batch_size"`
+	LingerMs    int    `yaml:"linger_ms"`
+	Compression string `yaml:"compression"`
+	MaxAttempts int    `yaml:"max_attempts"`
 }
 
 type ScraperConfig struct {
 	PollIntervalSeconds int `yaml:"poll_interval_seconds"`
 	StoriesToFetch      int `yaml:"stories_to_fetch"`
+	// Workers is the number of items fetched in parallel across all sources
+	// (default 8)
+	Workers int `yaml:"workers"`
+	// RequestsPerSecond caps requests to any single host (0 = unlimited)
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	// SeenStorePath is where dedup state is persisted (default seen_stories.json)
+	SeenStorePath string `yaml:"seen_store_path"`
+	// SeenTTLDays evicts IDs not observed in a feed for this many days (default 7)
+	SeenTTLDays int           `yaml:"seen_ttl_days"`
+	Updates     UpdatesConfig `yaml:"updates"`
+	// Feeds lists the Hacker News feeds to poll (default: top and new)
+	Feeds []FeedConfig `yaml:"feeds"`
+	// Sources lists additional, non-HN sites to poll
+	Sources []SourceConfig `yaml:"sources"`
+	// Incremental makes HN feeds fetch only items that changed between polls
+	Incremental IncrementalConfig `yaml:"incremental"`
+}
+
+// UpdatesConfig controls republishing of stories that changed after they
+// were first published. Title and URL changes always trigger an update.
+type UpdatesConfig struct {
+	Enabled       bool `yaml:"enabled"`
+	ScoreDelta    int  `yaml:"score_delta"`    // default 10
+	CommentsDelta int  `yaml:"comments_delta"` // default 10
 }
 
 type Story struct {
@@ -52,18 +110,43 @@ type Story struct {
 	Score int    `json:"score"`
 	Time  int64  `json:"time"`
 	Type  string `json:"type"`
+	// Descendants is the total comment count
+	Descendants int `json:"descendants"`
+	// Site is the host the story was collected from, e.g. news.ycombinator.com
+	Site string `json:"site,omitempty"`
+	// Deleted and Dead are set by HN for removed and flagged items
+	Deleted bool `json:"deleted,omitempty"`
+	Dead    bool `json:"dead,omitempty"`
+}
+
+// publishRequest is a deduplicated story waiting to be published
+type publishRequest struct {
+	story     *Story
+	source    string
+	feeds     []string // every feed the story has appeared in, sorted
+	event     string   // eventCreated, eventUpdated or eventDeleted
+	fetchedAt time.Time
 }
 
 type Scraper struct {
-	client       *http.Client
-	seenStories  map[int]bool
-	mu           sync.Mutex
-	config       Config
-	kafkaWriter  *kafka.Writer
-	pollInterval time.Duration
-	storiesToFetch int
-	ctx          context.Context
-	cancel       context.CancelFunc
+	client        *http.Client
+	seenStories   *SeenStore   // published successfully
+	pending       map[int]bool // queued or being published
+	mu            sync.Mutex
+	publishQueue  chan publishRequest
+	publishWG     sync.WaitGroup
+	config        Config
+	publisher     Publisher
+	spool         *Spool // nil when disabled
+	spoolInterval time.Duration
+	schemaVersion int
+	sources       []Source
+	workers       int
+	fetchSlots    chan struct{} // shared by every source's fetches
+	updates       UpdatesConfig
+	limiter       *hostLimiter
+	ctx           context.Context
+	cancel        context.CancelFunc
 }
 
 func loadConfig(path string) (*Config, error) {
@@ -98,63 +181,133 @@ func loadConfig(path string) (*Config, error) {
 	return &cfg, nil
 }
 
-func createKafkaWriter(cfg KafkaConfig) (*kafka.Writer, error) {
-	keypair, err := tls.LoadX509KeyPair(cfg.ClientCertPath, cfg.ClientKeyPath)
+func NewScraper(cfg Config) (*Scraper, error) {
+	schemaVersion := cfg.Publisher.SchemaVersion
+	if schemaVersion == 0 {
+		schemaVersion = currentSchemaVersion
+	}
+	if schemaVersion != schemaVersionLegacy && schemaVersion != schemaVersionEnvelope {
+		return nil, fmt.Errorf("unsupported schema_version %d (want 1 or 2)", schemaVersion)
+	}
+
+	publisher, err := newPublisher(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load client cert/key: %w", err)
+		return nil, fmt.Errorf("failed to create publisher: %w", err)
 	}
 
-	caCert, err := os.ReadFile(cfg.CACertPath)
+	workers := cfg.Scraper.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+
+	seenPath := cfg.Scraper.SeenStorePath
+	if seenPath == "" {
+		seenPath = defaultSeenStorePath
+	}
+	seenTTLDays := cfg.Scraper.SeenTTLDays
+	if seenTTLDays <= 0 {
+		seenTTLDays = defaultSeenTTLDays
+	}
+	updates := cfg.Scraper.Updates
+	if updates.ScoreDelta <= 0 {
+		updates.ScoreDelta = defaultUpdateScoreDelta
+	}
+	if updates.CommentsDelta <= 0 {
+		updates.CommentsDelta = defaultUpdateCommentsDelta
+	}
+
+	var spool *Spool
+	if cfg.Publisher.Spool.Dir != "" {
+		spool, err = NewSpool(cfg.Publisher.Spool.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open spool: %w", err)
+		}
+	}
+	spoolInterval := cfg.Publisher.Spool.DrainIntervalSeconds
+	if spoolInterval <= 0 {
+		spoolInterval = defaultSpoolDrainIntervalSeconds
+	}
+
+	seen, err := NewSeenStore(seenPath, time.Duration(seenTTLDays)*24*time.Hour)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read CA cert: %w", err)
+		return nil, fmt.Errorf("failed to load seen stories: %w", err)
 	}
 
-	caCertPool := x509.NewCertPool()
-	if !caCertPool.AppendCertsFromPEM(caCert) {
-		return nil, fmt.Errorf("failed to parse CA cert")
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scraper{
+		client:        &http.Client{Timeout: 10 * time.Second},
+		seenStories:   seen,
+		pending:       make(map[int]bool),
+		publishQueue:  make(chan publishRequest, publishQueueSize),
+		config:        cfg,
+		publisher:     publisher,
+		spool:         spool,
+		spoolInterval: time.Duration(spoolInterval) * time.Second,
+		schemaVersion: schemaVersion,
+		workers:       workers,
+		fetchSlots:    make(chan struct{}, workers),
+		updates:       updates,
+		limiter:       newHostLimiter(cfg.Scraper.RequestsPerSecond),
+		ctx:           ctx,
+		cancel:        cancel,
 	}
 
-	dialer := &kafka.Dialer{
-		Timeout:   10 * time.Second,
-		DualStack: true,
-		TLS: &tls.Config{
-			Certificates: []tls.Certificate{keypair},
-			RootCAs:      caCertPool,
-		},
+	s.sources, err = newSources(s, cfg.Scraper)
+	if err != nil {
+		cancel()
+		return nil, err
 	}
+	return s, nil
+}
 
-	writer := kafka.NewWriter(kafka.WriterConfig{
-		Brokers: []string{cfg.Broker},
-		Topic:   cfg.Topic,
-		Dialer:  dialer,
-	})
+// get performs a rate-limited GET that is cancelled on shutdown
+func (s *Scraper) get(rawURL string) (*http.Response, error) {
+	return s.getWithHeaders(rawURL, nil)
+}
+
+// getWithHeaders is get with extra request headers, e.g. for conditional GETs
+func (s *Scraper) getWithHeaders(rawURL string, header http.Header) (*http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.limiter.wait(s.ctx, u.Host); err != nil {
+		return nil, err
+	}
 
-	fmt.Printf("[DEBUG] Kafka writer configured for broker: %s, topic: %s\n", cfg.Broker, cfg.Topic)
-	return writer, nil
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	// Some sites (notably Reddit) throttle requests without a descriptive agent
+	req.Header.Set("User-Agent", userAgent)
+	return s.client.Do(req)
 }
 
-func NewScraper(cfg Config) (*Scraper, error) {
-	writer, err := createKafkaWriter(cfg.Kafka)
+// getJSON fetches rawURL and decodes the JSON response into v
+func (s *Scraper) getJSON(rawURL string, v any) error {
+	resp, err := s.get(rawURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Kafka writer: %w", err)
+		return fmt.Errorf("failed to fetch %s: %w", rawURL, err)
 	}
+	defer resp.Body.Close()
 
-	ctx, cancel := context.WithCancel(context.Background())
-	return &Scraper{
-		client:         &http.Client{Timeout: 10 * time.Second},
-		seenStories:    make(map[int]bool),
-		config:         cfg,
-		kafkaWriter:    writer,
-		pollInterval:   time.Duration(cfg.Scraper.PollIntervalSeconds) * time.Second,
-		storiesToFetch: cfg.Scraper.StoriesToFetch,
-		ctx:            ctx,
-		cancel:         cancel,
-	}, nil
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
 }
 
 // fetchStoryIDs fetches story IDs from the given endpoint
 func (s *Scraper) fetchStoryIDs(url string) ([]int, error) {
-	resp, err := s.client.Get(url)
+	resp, err := s.get(url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch story IDs: %w", err)
 	}
@@ -179,8 +332,7 @@ func (s *Scraper) fetchStoryIDs(url string) ([]int, error) {
 
 // fetchStory fetches details for a single story
 func (s *Scraper) fetchStory(id int) (*Story, error) {
-	url := fmt.Sprintf(itemURL, id)
-	resp, err := s.client.Get(url)
+	resp, err := s.get(fmt.Sprintf(itemURL, id))
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch story: %w", err)
 	}
@@ -199,199 +351,382 @@ func (s *Scraper) fetchStory(id int) (*Story, error) {
 	if err := json.Unmarshal(body, &story); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal story: %w", err)
 	}
+	story.Site = hnSite
 
 	return &story, nil
 }
 
-// publishStoryToKafka publishes a story to Kafka with retry logic
-func (s *Scraper) publishStoryToKafka(story *Story, source string) error {
-	storyJSON, err := json.Marshal(story)
+// publishStory hands a story event to the publisher. done is called once the
+// publisher has delivered it or given up after its retries. With a spool, a
+// failed message is spooled instead and counts as delivered; messages for a
+// story that is already spooled go straight to the spool to keep them in order.
+func (s *Scraper) publishStory(req publishRequest, done func(error)) {
+	msg, err := encodeMessage(req, s.schemaVersion)
 	if err != nil {
-		return fmt.Errorf("failed to marshal story: %w", err)
+		done(err)
+		return
 	}
 
-	maxRetries := 5
-	backoffDuration := time.Second
+	if s.spool != nil && s.spool.Holds(msg.Key) {
+		done(s.spoolMessage(req, msg, nil))
+		return
+	}
 
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		// Check if we're shutting down before each attempt
-		if s.ctx.Err() != nil {
-			return fmt.Errorf("shutdown in progress")
+	s.publisher.Publish(s.ctx, msg, func(err error) {
+		if err != nil {
+			err = fmt.Errorf("failed to publish story %d: %w", req.story.ID, err)
+			if s.spool != nil {
+				err = s.spoolMessage(req, msg, err)
+			}
+			done(err)
+			return
 		}
+		fmt.Printf("[PUBLISHED] %s/%s | %s (Story ID: %d)\n", req.source, req.event, req.story.Title, req.story.ID)
+		done(nil)
+	})
+}
 
-		msg := kafka.Message{
-			Key:   []byte(source),
-			Value: storyJSON,
+// spoolMessage writes a message to the spool for the drainer to replay. It
+// returns publishErr if the message could not be spooled either.
+func (s *Scraper) spoolMessage(req publishRequest, msg Message, publishErr error) error {
+	if err := s.spool.Add(msg); err != nil {
+		fmt.Printf("[ERROR] Failed to spool story %d: %v\n", req.story.ID, err)
+		if publishErr == nil {
+			return err
 		}
+		return publishErr
+	}
 
-		// Publish with a timeout using goroutine
-		errChan := make(chan error, 1)
-		go func() {
-			writeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
-			errChan <- s.kafkaWriter.WriteMessages(writeCtx, msg)
-		}()
+	if publishErr != nil {
+		fmt.Printf("[SPOOL] %v; spooled for retry (%d spooled)\n", publishErr, s.spool.Depth())
+	} else {
+		fmt.Printf("[SPOOL] Story %d has earlier events spooled; spooled behind them (%d spooled)\n",
+			req.story.ID, s.spool.Depth())
+	}
+	return nil
+}
 
+// drainSpool replays spooled messages on the drain interval until shutdown
+func (s *Scraper) drainSpool() {
+	defer s.publishWG.Done()
+
+	ticker := time.NewTicker(s.spoolInterval)
+	defer ticker.Stop()
+
+	for {
 		select {
-		case err := <-errChan:
-			if err == nil {
-				fmt.Printf("[PUBLISHED] %s | %s (Story ID: %d)\n", source, story.Title, story.ID)
-				return nil
-			}
-			// Publish failed, will retry
-		case <-time.After(6 * time.Second):
-			err = fmt.Errorf("publish timeout")
+		case <-ticker.C:
 		case <-s.ctx.Done():
-			return fmt.Errorf("shutdown in progress")
+			return
 		}
 
-		// Check if context was cancelled (shutdown signal)
-		if s.ctx.Err() != nil {
-			return fmt.Errorf("shutdown in progress")
+		if s.spool.Depth() == 0 {
+			continue
 		}
-
-		if attempt < maxRetries {
-			fmt.Printf("[RETRY] Publishing story %d (attempt %d/%d, waiting %v): %v\n",
-				story.ID, attempt+1, maxRetries, backoffDuration, err)
-			
-			// Sleep with early exit on shutdown
-			select {
-			case <-time.After(backoffDuration):
-				backoffDuration *= 2
-			case <-s.ctx.Done():
-				return fmt.Errorf("shutdown in progress")
-			}
+		replayed, err := s.spool.Drain(s.ctx, s.publisher)
+		if err != nil && s.ctx.Err() == nil {
+			fmt.Printf("[SPOOL] Replayed %d messages, %d still spooled: %v\n", replayed, s.spool.Depth(), err)
+		} else if replayed > 0 {
+			fmt.Printf("[SPOOL] Replayed %d messages, %d still spooled\n", replayed, s.spool.Depth())
 		}
 	}
-
-	return fmt.Errorf("failed to publish story %d after %d retries: %v", story.ID, maxRetries, err)
 }
 
-// addAndPublishStory adds a story to seen map and publishes if new
-func (s *Scraper) addAndPublishStory(story *Story, source string) {
+// enqueueStory is the dedup stage of the pipeline: it queues a story for
+// publishing if it is new, has turned up in a feed it wasn't published with
+// or, with updates enabled, has changed enough since it was last published. A
+// published story that HN has since deleted or killed is queued as a deletion.
+// Stories already in flight are skipped. The lock is only held for the
+// lookups, never across the publish.
+func (s *Scraper) enqueueStory(story *Story, source string, fetchedAt time.Time) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	if s.pending[story.ID] {
+		s.mu.Unlock()
+		return
+	}
+	prev, seen := s.seenStories.Get(story.ID)
+	feeds, newFeed := addFeed(prev.Feeds, source)
+	var event string
+	switch {
+	case story.Deleted || story.Dead:
+		if !seen {
+			s.mu.Unlock()
+			return
+		}
+		event = eventDeleted
+	case story.Title == "":
+		// Nothing to show for items without a title
+		s.mu.Unlock()
+		return
+	case !seen:
+		event = eventCreated
+	case newFeed:
+		// Consumers filter on feed membership, so this is published even
+		// without updates enabled
+		event = eventUpdated
+	case s.updates.Enabled && s.changedSince(prev, story):
+		event = eventUpdated
+	default:
+		s.mu.Unlock()
+		return
+	}
+	s.pending[story.ID] = true
+	s.mu.Unlock()
 
-	if !s.seenStories[story.ID] {
-		s.seenStories[story.ID] = true
+	switch event {
+	case eventCreated:
 		fmt.Printf("[NEW] %s\n", story.Title)
 		if story.URL != "" {
 			fmt.Printf("      %s\n", story.URL)
 		}
+	case eventUpdated:
+		fmt.Printf("[UPDATE] %s (score %d, comments %d, feeds %s)\n",
+			story.Title, story.Score, story.Descendants, strings.Join(feeds, ", "))
+	case eventDeleted:
+		fmt.Printf("[DELETE] %s (Story ID: %d)\n", prev.Title, story.ID)
+	}
 
-		// Publish to Kafka
-		if err := s.publishStoryToKafka(story, source); err != nil {
-			fmt.Printf("[ERROR] %v\n", err)
-		}
+	req := publishRequest{story: story, source: source, feeds: feeds, event: event, fetchedAt: fetchedAt}
+	select {
+	case s.publishQueue <- req:
+	case <-s.ctx.Done():
+		s.mu.Lock()
+		delete(s.pending, story.ID)
+		s.mu.Unlock()
 	}
 }
 
-// pollStories polls both top and new stories
-func (s *Scraper) pollStories() {
-	sources := map[string]string{
-		"top": topStoriesURL,
-		"new": newStoriesURL,
+// changedSince reports whether story differs enough from the published version
+// to warrant an update event
+func (s *Scraper) changedSince(prev seenEntry, story *Story) bool {
+	// Entries loaded from an old seen store have no snapshot to compare against
+	if prev.Title == "" {
+		return false
 	}
+	if story.Title != prev.Title || story.URL != prev.URL {
+		return true
+	}
+	if abs(story.Score-prev.Score) >= s.updates.ScoreDelta {
+		return true
+	}
+	return abs(story.Descendants-prev.Descendants) >= s.updates.CommentsDelta
+}
 
-	for source, url := range sources {
-		// Check if shutting down
+// addFeed returns feeds with feed added, keeping them sorted, and whether it
+// was missing
+func addFeed(feeds []string, feed string) ([]string, bool) {
+	i, found := slices.BinarySearch(feeds, feed)
+	if found {
+		return feeds, false
+	}
+	return slices.Insert(slices.Clone(feeds), i, feed), true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// publishWorker drains the publish queue into the publisher. Publishing is
+// asynchronous; the outcome is handled by finishPublish.
+func (s *Scraper) publishWorker() {
+	defer s.publishWG.Done()
+
+	for {
 		select {
+		case req := <-s.publishQueue:
+			s.publishStory(req, func(err error) {
+				s.finishPublish(req, err)
+			})
 		case <-s.ctx.Done():
 			return
-		default:
 		}
+	}
+}
 
-		ids, err := s.fetchStoryIDs(url)
-		if err != nil {
-			fmt.Printf("Error fetching story IDs from %s: %v\n", url, err)
-			continue
-		}
+// finishPublish releases a story once its publish has completed. A story is
+// only marked as seen once the publisher has delivered it; if delivery failed
+// it is released so the next poll picks it up again.
+func (s *Scraper) finishPublish(req publishRequest, err error) {
+	s.mu.Lock()
+	delete(s.pending, req.story.ID)
+	if err == nil && req.event == eventDeleted {
+		s.seenStories.Remove(req.story.ID)
+	} else if err == nil {
+		s.seenStories.Mark(req.story, req.feeds)
+	}
+	s.mu.Unlock()
 
-		// Only fetch the top N
-		if len(ids) > s.storiesToFetch {
-			ids = ids[:s.storiesToFetch]
-		}
+	if err != nil && s.ctx.Err() == nil {
+		fmt.Printf("[ERROR] %v (will retry on next poll)\n", err)
+	}
+}
 
-		for _, id := range ids {
-			// Check if shutting down
-			select {
-			case <-s.ctx.Done():
-				return
-			default:
-			}
+// pollSource fetches a source's current stories and queues them for
+// publishing, keyed by the source name
+func (s *Scraper) pollSource(src Source) {
+	fetchedAt := time.Now()
+	stories, err := src.Fetch()
+	if err != nil {
+		if s.ctx.Err() == nil {
+			fmt.Printf("Error fetching stories from %s: %v\n", src.Name(), err)
+		}
+		return
+	}
 
-			story, err := s.fetchStory(id)
-			if err != nil {
-				fmt.Printf("Error fetching story %d: %v\n", id, err)
-				continue
-			}
+	for _, story := range stories {
+		// Check if shutting down
+		select {
+		case <-s.ctx.Done():
+			return
+		default:
+		}
 
-			// Only process stories with titles (filter out deleted/dead stories)
-			if story.Title != "" {
-				s.addAndPublishStory(story, source)
-			}
+		if story != nil {
+			s.enqueueStory(story, src.Name(), fetchedAt)
 		}
 	}
 }
 
-// run starts the polling loop
-func (s *Scraper) run() {
-	ticker := time.NewTicker(s.pollInterval)
+// runSource polls a source immediately and then on its own interval until
+// shutdown
+func (s *Scraper) runSource(src Source) {
+	ticker := time.NewTicker(src.PollInterval())
 	defer ticker.Stop()
 
-	// Poll immediately on startup
-	fmt.Println("Starting Hacker News scraper...")
-	s.pollStories()
-
 	for {
+		s.pollSource(src)
+		s.saveSeenStories()
+
 		select {
 		case <-ticker.C:
-			s.pollStories()
 		case <-s.ctx.Done():
-			fmt.Println("\nShutting down Kafka writer...")
-			// Give pending writes 2 seconds to complete
-			shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-			defer cancel()
-			
-			// Try to close gracefully
-			closeDone := make(chan struct{})
-			go func() {
-				if err := s.kafkaWriter.Close(); err != nil {
-					fmt.Printf("Error closing Kafka writer: %v\n", err)
+			return
+		}
+	}
+}
+
+// fetchStories fetches the given items using the worker pool. Sources poll
+// concurrently, so workers take a slot from fetchSlots for each item to keep
+// the total in flight at s.workers. The result has the same order as ids;
+// items that failed to fetch are nil. All workers have exited by the time it
+// returns, including when the scraper is shutting down.
+func (s *Scraper) fetchStories(ids []int) []*Story {
+	stories := make([]*Story, len(ids))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < s.workers && w < len(ids); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				select {
+				case s.fetchSlots <- struct{}{}:
+				case <-s.ctx.Done():
+					continue
+				}
+				story, err := s.fetchStory(ids[i])
+				<-s.fetchSlots
+				if err != nil {
+					if s.ctx.Err() == nil {
+						fmt.Printf("Error fetching story %d: %v\n", ids[i], err)
+					}
+					continue
 				}
-				closeDone <- struct{}{}
-			}()
-			
-			select {
-			case <-closeDone:
-				fmt.Println("Scraper shutdown complete.")
-			case <-shutdownCtx.Done():
-				fmt.Println("Forced shutdown after timeout.")
+				stories[i] = story
 			}
-			return
+		}()
+	}
+
+feed:
+	for i := range ids {
+		select {
+		case jobs <- i:
+		case <-s.ctx.Done():
+			break feed
 		}
 	}
+	close(jobs)
+	wg.Wait()
+
+	return stories
 }
 
-// stop signals the scraper to stop
-func (s *Scraper) stop() {
-	s.cancel()
+// saveSeenStories evicts expired dedup entries and persists the rest
+func (s *Scraper) saveSeenStories() {
+	if evicted := s.seenStories.Evict(); evicted > 0 {
+		fmt.Printf("[SEEN] Evicted %d expired story IDs\n", evicted)
+	}
+	if err := s.seenStories.Save(); err != nil {
+		fmt.Printf("[ERROR] Failed to save seen stories: %v\n", err)
+	}
 }
 
-func main() {
-	cfg, err := loadConfig("config.yaml")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
-		os.Exit(1)
+// run starts a polling loop per feed and blocks until shutdown
+func (s *Scraper) run() {
+	s.publishWG.Add(1)
+	go s.publishWorker()
+	if s.spool != nil {
+		s.publishWG.Add(1)
+		go s.drainSpool()
 	}
 
-	scraper, err := NewScraper(*cfg)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to initialize scraper: %v\n", err)
-		os.Exit(1)
+	fmt.Println("Starting story scraper...")
+	var sourceWG sync.WaitGroup
+	for _, src := range s.sources {
+		fmt.Printf("[SOURCE] %s: polling every %v\n", src.Name(), src.PollInterval())
+		sourceWG.Add(1)
+		go func(src Source) {
+			defer sourceWG.Done()
+			s.runSource(src)
+		}(src)
 	}
 
-	// Handle graceful shutdown
+	<-s.ctx.Done()
+
+	// Let in-flight fetches observe the cancellation, then flush the publisher
+	// so delivered stories are recorded before the seen store is saved
+	sourceWG.Wait()
+	s.publishWG.Wait()
+	s.closePublisher()
+	s.saveSeenStories()
+}
+
+// closePublisher flushes and closes the publisher, giving up after a timeout
+func (s *Scraper) closePublisher() {
+	fmt.Println("\nShutting down publisher...")
+	// Give pending writes 2 seconds to complete
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Try to close gracefully
+	closeDone := make(chan struct{})
+	go func() {
+		if err := s.publisher.Close(); err != nil {
+			fmt.Printf("Error closing publisher: %v\n", err)
+		}
+		closeDone <- struct{}{}
+	}()
+
+	select {
+	case <-closeDone:
+		fmt.Println("Scraper shutdown complete.")
+	case <-shutdownCtx.Done():
+		fmt.Println("Forced shutdown after timeout.")
+	}
+}
+
+// stop signals the scraper to stop
+func (s *Scraper) stop() {
+	s.cancel()
+}
+
+// handleSignals stops the scraper on SIGINT/SIGTERM and force-exits if
+// cleanup takes too long
+func handleSignals(scraper *Scraper) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
@@ -399,13 +734,40 @@ func main() {
 		sig := <-sigChan
 		fmt.Printf("\n[SIGNAL] Received %v, shutting down...\n", sig)
 		scraper.stop()
-		
+
 		// Force exit if cleanup takes too long
 		fmt.Println("[WAITING] Giving 3 seconds for graceful shutdown...")
 		time.Sleep(3 * time.Second)
 		fmt.Println("[SHUTDOWN] Timeout reached, forcing exit...")
 		os.Exit(0)
 	}()
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		if err := runBackfill(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Backfill failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cfg, err := loadConfig("config.yaml")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	scraper, err := NewScraper(*cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize scraper: %v\n", err)
+		os.Exit(1)
+	}
+
+	startMetricsServer(cfg.Metrics)
+
+	// Handle graceful shutdown
+	handleSignals(scraper)
 
 	scraper.run()
 }
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakePublisher records the messages it delivers. fail, if set, decides which
+// messages fail instead.
+type fakePublisher struct {
+	mu   sync.Mutex
+	msgs []Message
+	fail func(Message) error
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, msg Message, done func(error)) {
+	p.mu.Lock()
+	var err error
+	if p.fail != nil {
+		err = p.fail(msg)
+	}
+	if err == nil {
+		p.msgs = append(p.msgs, msg)
+	}
+	p.mu.Unlock()
+	done(err)
+}
+
+func (p *fakePublisher) Close() error { return nil }
+
+// published returns the keys of the delivered messages, in delivery order
+func (p *fakePublisher) published() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	keys := make([]string, len(p.msgs))
+	for i, msg := range p.msgs {
+		keys[i] = msg.Key
+	}
+	return keys
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// Sources poll concurrently, so the worker bound has to hold across
+// concurrent fetchStories calls, not just within one
+func TestFetchStoriesSharesWorkers(t *testing.T) {
+	const workers = 3
+	var inFlight, maxInFlight atomic.Int32
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			max := maxInFlight.Load()
+			if n <= max || maxInFlight.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		var id int
+		fmt.Sscanf(r.URL.Path, "/v0/item/%d.json", &id)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(fmt.Sprintf(`{"id":%d}`, id))),
+			Request:    r,
+		}, nil
+	})
+
+	s := &Scraper{
+		client:     &http.Client{Transport: transport},
+		limiter:    newHostLimiter(0),
+		workers:    workers,
+		fetchSlots: make(chan struct{}, workers),
+		ctx:        context.Background(),
+	}
+
+	var wg sync.WaitGroup
+	for source := 0; source < 4; source++ {
+		ids := make([]int, 6)
+		for i := range ids {
+			ids[i] = source*100 + i + 1
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i, story := range s.fetchStories(ids) {
+				if story == nil || story.ID != ids[i] {
+					t.Errorf("item %d: got %+v", ids[i], story)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := maxInFlight.Load(); got > workers {
+		t.Errorf("%d fetches in flight, want at most %d", got, workers)
+	}
+}
+
+// A story is published once per feed it turns up in, with every feed it has
+// appeared in, even without updates enabled
+func TestEnqueueStoryTracksFeeds(t *testing.T) {
+	seen, err := NewSeenStore("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Scraper{
+		seenStories:  seen,
+		pending:      make(map[int]bool),
+		publishQueue: make(chan publishRequest, 10),
+		ctx:          context.Background(),
+	}
+	story := &Story{ID: 1, Title: "Rust 2.0", Score: 10}
+
+	tests := []struct {
+		source string
+		event  string // "" when nothing should be published
+		feeds  []string
+	}{
+		{"new", eventCreated, []string{"new"}},
+		{"new", "", nil},
+		{"top", eventUpdated, []string{"new", "top"}},
+		{"top", "", nil},
+		{"new", "", nil},
+		{"best", eventUpdated, []string{"best", "new", "top"}},
+	}
+	for i, tt := range tests {
+		s.enqueueStory(story, tt.source, time.Now())
+		select {
+		case req := <-s.publishQueue:
+			if req.event != tt.event || strings.Join(req.feeds, ",") != strings.Join(tt.feeds, ",") {
+				t.Errorf("poll %d (%s): published %s %v, want %q %v", i, tt.source, req.event, req.feeds, tt.event, tt.feeds)
+			}
+			s.finishPublish(req, nil)
+		default:
+			if tt.event != "" {
+				t.Errorf("poll %d (%s): nothing published, want %s %v", i, tt.source, tt.event, tt.feeds)
+			}
+		}
+	}
+}
+
+func TestEncodeMessageFeeds(t *testing.T) {
+	req := publishRequest{
+		story:     &Story{ID: 7, Title: "Zig"},
+		source:    "top",
+		feeds:     []string{"new", "top"},
+		event:     eventUpdated,
+		fetchedAt: time.Now(),
+	}
+	for _, version := range []int{schemaVersionLegacy, schemaVersionEnvelope} {
+		msg, err := encodeMessage(req, version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := msg.Headers["feeds"]; got != "new,top" {
+			t.Errorf("v%d: feeds header %q, want %q", version, got, "new,top")
+		}
+	}
+
+	msg, err := encodeMessage(req, schemaVersionEnvelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var env Envelope
+	if err := json.Unmarshal(msg.Value, &env); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(env.Feeds, ",") != "new,top" || env.Source != "top" {
+		t.Errorf("envelope source %q, feeds %v", env.Source, env.Feeds)
+	}
+}
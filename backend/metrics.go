@@ -0,0 +1,31 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+)
+
+// MetricsConfig enables the metrics endpoint
+type MetricsConfig struct {
+	// ListenAddress serves expvar metrics at /debug/vars, e.g. ":9100";
+	// empty disables the endpoint
+	ListenAddress string `yaml:"listen_address"`
+}
+
+// startMetricsServer serves the process's expvar metrics in the background
+func startMetricsServer(cfg MetricsConfig) {
+	if cfg.ListenAddress == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	fmt.Printf("[METRICS] Serving metrics on %s/debug/vars\n", cfg.ListenAddress)
+	go func() {
+		if err := http.ListenAndServe(cfg.ListenAddress, mux); err != nil {
+			fmt.Printf("[ERROR] Metrics server stopped: %v\n", err)
+		}
+	}()
+}
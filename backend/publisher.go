@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// PublisherConfig selects where stories are published
+type PublisherConfig struct {
+	Type string `yaml:"type"` // kafka (default), file or stdout
+	Path string `yaml:"path"` // file: output path, appended to
+	// SchemaVersion is the message format to produce: 1 (bare story) or 2
+	// (envelope, default)
+	SchemaVersion int `yaml:"schema_version"`
+	// Spool keeps messages that fail to publish on disk until they can be
+	// replayed
+	Spool SpoolConfig `yaml:"spool"`
+}
+
+// Message is a story ready to be published
+type Message struct {
+	Key     string
+	Value   []byte
+	Headers map[string]string
+}
+
+// Publisher delivers messages to a sink. Publish may return before delivery;
+// done is called exactly once with the final outcome, possibly from another
+// goroutine. Close flushes outstanding messages. Publish must be safe to call
+// from multiple goroutines.
+type Publisher interface {
+	Publish(ctx context.Context, msg Message, done func(error))
+	Close() error
+}
+
+// newPublisher creates the publisher selected in the config. Only the Kafka
+// publisher needs broker settings and certificates.
+func newPublisher(cfg Config) (Publisher, error) {
+	switch cfg.Publisher.Type {
+	case "", "kafka":
+		writer, err := createKafkaWriter(cfg.Kafka)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Kafka writer: %w", err)
+		}
+		return &kafkaPublisher{writer: writer}, nil
+	case "file":
+		if cfg.Publisher.Path == "" {
+			return nil, fmt.Errorf("file publisher needs a path")
+		}
+		f, err := os.OpenFile(cfg.Publisher.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", cfg.Publisher.Path, err)
+		}
+		fmt.Printf("[DEBUG] Publishing stories to file: %s\n", cfg.Publisher.Path)
+		return newJSONLinesPublisher(f, f), nil
+	case "stdout":
+		// The messages get stdout to themselves; everything the scraper
+		// logs with fmt.Printf goes to stderr instead
+		out := os.Stdout
+		os.Stdout = os.Stderr
+		return newJSONLinesPublisher(out, nil), nil
+	default:
+		return nil, fmt.Errorf("unknown publisher type %q (want kafka, file or stdout)", cfg.Publisher.Type)
+	}
+}
+
+// kafkaPublisher writes messages to a Kafka topic asynchronously. The writer
+// batches messages per partition and reports each batch through its
+// Completion hook, which calls back the done func carried in WriterData.
+type kafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+func (k *kafkaPublisher) Publish(ctx context.Context, msg Message, done func(error)) {
+	km := kafka.Message{
+		Key:        []byte(msg.Key),
+		Value:      msg.Value,
+		WriterData: done,
+	}
+	for key, value := range msg.Headers {
+		km.Headers = append(km.Headers, kafka.Header{Key: key, Value: []byte(value)})
+	}
+	// In async mode this only fails if the message can't be queued at all
+	if err := k.writer.WriteMessages(ctx, km); err != nil {
+		done(err)
+	}
+}
+
+// completeKafkaBatch is the writer's Completion hook
+func completeKafkaBatch(messages []kafka.Message, err error) {
+	for _, m := range messages {
+		if done, ok := m.WriterData.(func(error)); ok {
+			done(err)
+		}
+	}
+}
+
+func (k *kafkaPublisher) Close() error {
+	return k.writer.Close()
+}
+
+// jsonLinesPublisher writes one JSON object per message and line, e.g.
+// {"key":"42","headers":{"event":"created"},"value":{...story...}}
+type jsonLinesPublisher struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	closer io.Closer // nil for stdout
+}
+
+// jsonLine is the on-disk form of a message
+type jsonLine struct {
+	Key     string            `json:"key"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Value   json.RawMessage   `json:"value"`
+}
+
+func newJSONLinesPublisher(w io.Writer, closer io.Closer) *jsonLinesPublisher {
+	return &jsonLinesPublisher{w: bufio.NewWriter(w), closer: closer}
+}
+
+// Publish writes the message synchronously, calling done before returning
+func (j *jsonLinesPublisher) Publish(ctx context.Context, msg Message, done func(error)) {
+	done(j.write(msg))
+}
+
+func (j *jsonLinesPublisher) write(msg Message) error {
+	line, err := json.Marshal(jsonLine{Key: msg.Key, Headers: msg.Headers, Value: msg.Value})
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	// Flush per message so the output can be tailed
+	return j.w.Flush()
+}
+
+func (j *jsonLinesPublisher) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.w.Flush(); err != nil {
+		return err
+	}
+	if j.closer != nil {
+		return j.closer.Close()
+	}
+	return nil
+}
+
+// createKafkaWriter creates an asynchronous, batching writer. Messages are
+// partitioned by key (the story ID), so all events for a story stay in order.
+func createKafkaWriter(cfg KafkaConfig) (*kafka.Writer, error) {
+	dialer, err := createKafkaDialer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	compression, err := kafkaCompression(cfg.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultKafkaBatchSize
+	}
+	lingerMs := cfg.LingerMs
+	if lingerMs <= 0 {
+		lingerMs = defaultKafkaLingerMs
+	}
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultKafkaMaxAttempts
+	}
+
+	writer := &kafka.Writer{
+		Addr:            kafka.TCP(cfg.Broker),
+		Topic:           cfg.Topic,
+		Balancer:        &kafka.Hash{},
+		BatchSize:       batchSize,
+		BatchTimeout:    time.Duration(lingerMs) * time.Millisecond,
+		Compression:     compression,
+		MaxAttempts:     maxAttempts,
+		WriteBackoffMin: time.Second,
+		WriteBackoffMax: 16 * time.Second,
+		RequiredAcks:    kafka.RequireAll,
+		Async:           true,
+		Completion:      completeKafkaBatch,
+		Transport: &kafka.Transport{
+			DialTimeout: dialer.Timeout,
+			TLS:         dialer.TLS,
+			SASL:        dialer.SASLMechanism,
+		},
+		ErrorLogger: kafka.LoggerFunc(func(msg string, args ...interface{}) {
+			fmt.Printf("[KAFKA] "+msg+"\n", args...)
+		}),
+	}
+
+	fmt.Printf("[DEBUG] Kafka writer configured for broker: %s, topic: %s (batch %d, linger %dms, compression %s)\n",
+		cfg.Broker, cfg.Topic, batchSize, lingerMs, compressionName(cfg.Compression))
+	return writer, nil
+}
+
+// kafkaCompression maps a codec name to the writer setting
+func kafkaCompression(name string) (kafka.Compression, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return 0, nil
+	case "gzip":
+		return kafka.Gzip, nil
+	case "snappy":
+		return kafka.Snappy, nil
+	case "lz4":
+		return kafka.Lz4, nil
+	case "zstd":
+		return kafka.Zstd, nil
+	default:
+		return 0, fmt.Errorf("unknown compression %q (want none, gzip, snappy, lz4 or zstd)", name)
+	}
+}
+
+func compressionName(name string) string {
+	if name == "" {
+		return "none"
+	}
+	return name
+}
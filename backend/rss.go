@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rssSource polls an RSS 2.0, RSS 1.0 (RDF) or Atom feed. The url may also be
+// a local file path or file:// URL, which is handy for trying out fixture
+// feeds without a network.
+type rssSource struct {
+	sourceInfo
+	scraper *Scraper
+	url     string
+
+	// Validators and items from the last successful fetch, used for
+	// conditional GETs
+	mu           sync.Mutex
+	etag         string
+	lastModified string
+	last         []*Story
+}
+
+// feedDocument covers every supported format: RSS 2.0 puts items under
+// <rss><channel>, RSS 1.0 puts them directly under <rdf:RDF>, and Atom puts
+// entries directly under <feed>
+type feedDocument struct {
+	XMLName     xml.Name
+	ChannelLink string      `xml:"channel>link"`
+	Items       []rssItem   `xml:"channel>item"`
+	RDFItems    []rssItem   `xml:"item"`
+	FeedLinks   []atomLink  `xml:"link"`
+	Entries     []atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	Title   string `xml:"title"`
+	Link    string `xml:"link"`
+	GUID    string `xml:"guid"`
+	About   string `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	PubDate string `xml:"pubDate"`
+	Date    string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Author  string `xml:"author"`
+	Creator string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type atomEntry struct {
+	Title     string `xml:"title"`
+	ID        string `xml:"id"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+	Author    struct {
+		Name string `xml:"name"`
+	} `xml:"author"`
+	Links []atomLink `xml:"link"`
+}
+
+// alternateLink returns the alternate (or first) link of an Atom feed or entry
+func alternateLink(links []atomLink) string {
+	for _, l := range links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return l.Href
+		}
+	}
+	if len(links) > 0 {
+		return links[0].Href
+	}
+	return ""
+}
+
+// Fetch returns the feed's items. When the feed hasn't changed since the last
+// poll the previous items are returned without downloading or parsing it
+// again, which keeps them fresh in the dedup store.
+func (r *rssSource) Fetch() ([]*Story, error) {
+	body, err := r.open()
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.last, nil
+	}
+	defer body.Close()
+
+	stories, err := parseFeed(body, r.url)
+	if err != nil {
+		return nil, err
+	}
+	if len(stories) > r.storiesToFetch {
+		stories = stories[:r.storiesToFetch]
+	}
+
+	r.mu.Lock()
+	r.last = stories
+	r.mu.Unlock()
+	return stories, nil
+}
+
+// open returns the feed body, or nil if it is unchanged since the last fetch.
+// Remote feeds use ETag/Last-Modified; local files use the modification time.
+func (r *rssSource) open() (io.ReadCloser, error) {
+	if path, ok := localFeedPath(r.url); ok {
+		return r.openFile(path)
+	}
+
+	r.mu.Lock()
+	header := http.Header{}
+	if r.etag != "" {
+		header.Set("If-None-Match", r.etag)
+	}
+	if r.lastModified != "" {
+		header.Set("If-Modified-Since", r.lastModified)
+	}
+	r.mu.Unlock()
+
+	resp, err := r.scraper.getWithHeaders(r.url, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch feed: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		r.mu.Lock()
+		r.etag = resp.Header.Get("ETag")
+		r.lastModified = resp.Header.Get("Last-Modified")
+		r.mu.Unlock()
+		return resp.Body, nil
+	case http.StatusNotModified:
+		resp.Body.Close()
+		return nil, nil
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+}
+
+func (r *rssSource) openFile(path string) (io.ReadCloser, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed: %w", err)
+	}
+
+	modified := info.ModTime().UTC().Format(http.TimeFormat)
+	r.mu.Lock()
+	unchanged := r.lastModified == modified
+	r.lastModified = modified
+	r.mu.Unlock()
+	if unchanged {
+		return nil, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed: %w", err)
+	}
+	return f, nil
+}
+
+// localFeedPath reports whether rawURL names a local file (a file:// URL or a
+// plain path) and returns the path
+func localFeedPath(rawURL string) (string, bool) {
+	if strings.HasPrefix(rawURL, "file://") {
+		return strings.TrimPrefix(rawURL, "file://"), true
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" {
+		return rawURL, true
+	}
+	return "", false
+}
+
+// parseFeed decodes an RSS or Atom document into stories. The site is taken
+// from the feed's own link, falling back to the feed URL's host.
+func parseFeed(r io.Reader, feedURL string) ([]*Story, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = charsetReader
+
+	var doc feedDocument
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse feed: %w", err)
+	}
+
+	var stories []*Story
+	switch doc.XMLName.Local {
+	case "rss", "RDF":
+		items := doc.Items
+		siteLink := doc.ChannelLink
+		if doc.XMLName.Local == "RDF" {
+			items = doc.RDFItems
+		}
+		site := feedSite(siteLink, feedURL)
+		for _, item := range items {
+			link := strings.TrimSpace(item.Link)
+			guid := strings.TrimSpace(item.GUID)
+			if link == "" && strings.HasPrefix(guid, "http") {
+				link = guid
+			}
+			by := item.Creator
+			if by == "" {
+				by = item.Author
+			}
+			published := item.PubDate
+			if published == "" {
+				published = item.Date
+			}
+			key := firstNonEmpty(guid, strings.TrimSpace(item.About), link, item.Title+"|"+published)
+			stories = append(stories, feedStory(site, key, item.Title, link, by, published))
+		}
+	case "feed":
+		site := feedSite(alternateLink(doc.FeedLinks), feedURL)
+		for _, entry := range doc.Entries {
+			link := strings.TrimSpace(alternateLink(entry.Links))
+			published := firstNonEmpty(entry.Published, entry.Updated)
+			key := firstNonEmpty(strings.TrimSpace(entry.ID), link, entry.Title+"|"+published)
+			stories = append(stories, feedStory(site, key, entry.Title, link, entry.Author.Name, published))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported feed format <%s>", doc.XMLName.Local)
+	}
+	return stories, nil
+}
+
+// feedStory builds a story from a feed item. The ID is derived from the site
+// and the item's most stable identifier (guid/id, then link), so it stays the
+// same across polls and restarts and fits the int ID dedup.
+func feedStory(site, key, title, link, by, published string) *Story {
+	return &Story{
+		ID:    syntheticID(site, key),
+		Title: strings.TrimSpace(title),
+		URL:   link,
+		By:    strings.TrimSpace(by),
+		Time:  parseFeedTime(published),
+		Type:  "story",
+		Site:  site,
+	}
+}
+
+func feedSite(link, feedURL string) string {
+	if site := siteOf(strings.TrimSpace(link)); site != "" {
+		return site
+	}
+	if site := siteOf(feedURL); site != "" {
+		return site
+	}
+	return "local"
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// charsetReader lets the XML decoder read the common non-UTF-8 feed encodings
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "latin1", "latin-1":
+		return &latin1Reader{r: input}, nil
+	default:
+		return nil, fmt.Errorf("unsupported feed charset %q", charset)
+	}
+}
+
+// latin1Reader converts ISO-8859-1 bytes, which map 1:1 to code points, to UTF-8
+type latin1Reader struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (l *latin1Reader) Read(p []byte) (int, error) {
+	if len(l.buf) == 0 {
+		raw := make([]byte, len(p)/2+1)
+		n, err := l.r.Read(raw)
+		for _, b := range raw[:n] {
+			l.buf = append(l.buf, string(rune(b))...)
+		}
+		if n == 0 {
+			return 0, err
+		}
+	}
+	n := copy(p, l.buf)
+	l.buf = l.buf[n:]
+	return n, nil
+}
+
+// feedTimeLayouts are the date formats seen in RSS (RFC 822 and variants) and
+// Atom/Dublin Core (RFC 3339)
+var feedTimeLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02",
+}
+
+// parseFeedTime returns the unix time of a feed date, or now if it can't be parsed
+func parseFeedTime(value string) int64 {
+	value = strings.TrimSpace(value)
+	for _, layout := range feedTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Unix()
+		}
+	}
+	return time.Now().Unix()
+}
+
+// siteOf returns the host of rawURL without a leading www.
+func siteOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func parseFixture(t *testing.T, name, feedURL string) []*Story {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", "feeds", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	stories, err := parseFeed(f, feedURL)
+	if err != nil {
+		t.Fatalf("parseFeed(%s): %v", name, err)
+	}
+	return stories
+}
+
+func unix(t *testing.T, value string) int64 {
+	t.Helper()
+	ts, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts.Unix()
+}
+
+// checkStories compares the fields parseFeed derives from the feed
+func checkStories(t *testing.T, got, want []*Story) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d stories, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.ID != w.ID || g.Title != w.Title || g.URL != w.URL || g.By != w.By ||
+			g.Time != w.Time || g.Site != w.Site || g.Type != "story" {
+			t.Errorf("story %d = %+v, want %+v", i, *g, *w)
+		}
+	}
+}
+
+func TestParseFeedRSS(t *testing.T) {
+	stories := parseFixture(t, "example.rss", "https://www.example.com/blog/feed.xml")
+	checkStories(t, stories, []*Story{
+		{
+			ID:    syntheticID("example.com", "example-blog-0003"),
+			Title: "Profiling Go services in production",
+			URL:   "https://www.example.com/blog/profiling-go",
+			By:    "Ada Lovelace",
+			Time:  unix(t, "2025-01-14T09:30:00Z"),
+			Site:  "example.com",
+		},
+		{
+			ID:    syntheticID("example.com", "example-blog-0002"),
+			Title: "Why we moved our queue to Kafka",
+			URL:   "https://www.example.com/blog/kafka-queue",
+			By:    "grace@example.com (Grace Hopper)",
+			Time:  unix(t, "2025-01-06T16:00:00Z"),
+			Site:  "example.com",
+		},
+		{
+			// A permalink guid doubles as the link
+			ID:    syntheticID("example.com", "https://www.example.com/blog/permalink-guid"),
+			Title: "Permalink-only items still get stable IDs",
+			URL:   "https://www.example.com/blog/permalink-guid",
+			Time:  unix(t, "2025-01-01T07:00:00Z"),
+			Site:  "example.com",
+		},
+	})
+}
+
+func TestParseFeedRDF(t *testing.T) {
+	stories := parseFixture(t, "example.rdf", "https://news.example.net/index.rdf")
+	checkStories(t, stories, []*Story{
+		{
+			ID:    syntheticID("news.example.net", "https://news.example.net/stories/42"),
+			Title: "RDF feeds are still around",
+			URL:   "https://news.example.net/stories/42",
+			By:    "Tim Berners-Lee",
+			Time:  unix(t, "2025-01-10T07:45:00Z"),
+			Site:  "news.example.net",
+		},
+		{
+			ID:    syntheticID("news.example.net", "https://news.example.net/stories/41"),
+			Title: "Dublin Core dates explained",
+			URL:   "https://news.example.net/stories/41",
+			Time:  unix(t, "2025-01-09T00:00:00Z"),
+			Site:  "news.example.net",
+		},
+	})
+}
+
+func TestParseFeedAtom(t *testing.T) {
+	stories := parseFixture(t, "example.atom", "https://releases.example.org/feed.atom")
+	checkStories(t, stories, []*Story{
+		{
+			ID:    syntheticID("releases.example.org", "tag:releases.example.org,2025:2.0"),
+			Title: "Example 2.0 released",
+			URL:   "https://releases.example.org/2.0",
+			By:    "Release Team",
+			Time:  unix(t, "2025-01-15T12:00:00Z"),
+			Site:  "releases.example.org",
+		},
+		{
+			// No published date: updated is used instead
+			ID:    syntheticID("releases.example.org", "tag:releases.example.org,2025:1.9"),
+			Title: "Example 1.9 security fix",
+			URL:   "https://releases.example.org/1.9",
+			By:    "Security Team",
+			Time:  unix(t, "2025-01-02T07:15:00Z"),
+			Site:  "releases.example.org",
+		},
+	})
+}
+
+func TestParseFeedLatin1(t *testing.T) {
+	stories := parseFixture(t, "example-latin1.rss", "https://blog.example.fr/feed")
+	checkStories(t, stories, []*Story{
+		{
+			ID:    syntheticID("blog.example.fr", "cafe-001"),
+			Title: "Café et crème brûlée",
+			URL:   "https://blog.example.fr/cafe",
+			By:    "Renée",
+			Time:  unix(t, "2025-01-10T11:00:00Z"),
+			Site:  "blog.example.fr",
+		},
+	})
+}
+
+func TestParseFeedStableIDs(t *testing.T) {
+	first := parseFixture(t, "example.rss", "https://www.example.com/blog/feed.xml")
+	// The site comes from the channel link, so the feed URL doesn't matter
+	second := parseFixture(t, "example.rss", "file://testdata/feeds/example.rss")
+
+	seen := make(map[int]bool)
+	for i, story := range first {
+		if story.ID != second[i].ID {
+			t.Errorf("story %d: ID %d on the first parse, %d on the second", i, story.ID, second[i].ID)
+		}
+		if story.ID < 1<<52 || story.ID >= 1<<53 {
+			t.Errorf("story %d: ID %d outside [2^52, 2^53)", i, story.ID)
+		}
+		if seen[story.ID] {
+			t.Errorf("story %d: duplicate ID %d", i, story.ID)
+		}
+		seen[story.ID] = true
+	}
+}
+
+func TestParseFeedUnsupported(t *testing.T) {
+	if _, err := parseFeed(strings.NewReader(`<html><body/></html>`), ""); err == nil {
+		t.Error("parseFeed(<html>) succeeded, want an error")
+	}
+}
+
+func TestRSSSourceConditionalGet(t *testing.T) {
+	body, err := os.ReadFile(filepath.Join("testdata", "feeds", "example.rss"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const etag = `"v1"`
+	const lastModified = "Tue, 14 Jan 2025 09:30:00 GMT"
+	var requests, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == etag && r.Header.Get("If-Modified-Since") == lastModified {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	src := &rssSource{
+		sourceInfo: newSourceInfo("test", 0, 0, ScraperConfig{}),
+		scraper: &Scraper{
+			client:  server.Client(),
+			limiter: newHostLimiter(0),
+			ctx:     context.Background(),
+		},
+		url: server.URL + "/feed.xml",
+	}
+
+	first, err := src.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 3 {
+		t.Fatalf("first fetch returned %d stories, want 3", len(first))
+	}
+
+	second, err := src.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests.Load() != 2 || notModified.Load() != 1 {
+		t.Fatalf("got %d requests and %d 304 responses, want 2 and 1", requests.Load(), notModified.Load())
+	}
+	if len(second) != len(first) || &second[0] != &first[0] {
+		t.Error("a 304 response did not return the previous fetch's stories")
+	}
+}
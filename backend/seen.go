@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSeenStorePath = "seen_stories.json"
+	defaultSeenTTLDays   = 7
+)
+
+// seenEntry is what was last published for a story, used to detect changes
+type seenEntry struct {
+	LastSeen    int64  `json:"last_seen"` // unix seconds
+	Title       string `json:"title,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Score       int    `json:"score"`
+	Descendants int    `json:"descendants"`
+	// Feeds are the feeds the story has been published with, sorted
+	Feeds []string `json:"feeds,omitempty"`
+}
+
+// SeenStore is the scraper's dedup state: the published version of each story
+// and when it was last observed in a feed. It is persisted to a local JSON file
+// so restarts don't republish everything, and IDs that haven't been observed
+// within the TTL are evicted to keep it bounded.
+type SeenStore struct {
+	mu      sync.Mutex
+	saveMu  sync.Mutex // serializes Save calls from concurrent feeds
+	path    string
+	ttl     time.Duration
+	stories map[int]seenEntry
+}
+
+// NewSeenStore loads the store from path, or starts empty if the file does
+// not exist yet. An empty path keeps the state in memory only.
+func NewSeenStore(path string, ttl time.Duration) (*SeenStore, error) {
+	s := &SeenStore{
+		path:    path,
+		ttl:     ttl,
+		stories: make(map[int]seenEntry),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seen store: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.stories); err != nil {
+		// Files written before change tracking only hold last-seen times
+		var legacy map[int]int64
+		if legacyErr := json.Unmarshal(data, &legacy); legacyErr != nil {
+			return nil, fmt.Errorf("failed to parse seen store: %w", err)
+		}
+		for id, lastSeen := range legacy {
+			s.stories[id] = seenEntry{LastSeen: lastSeen}
+		}
+	}
+
+	evicted := s.Evict()
+	fmt.Printf("[SEEN] Loaded %d story IDs from %s (%d expired)\n", s.Len(), path, evicted)
+	return s, nil
+}
+
+// Get returns the last published version of a story, if any. A hit also
+// refreshes the last-seen time, so stories that stay in a feed are never
+// evicted.
+func (s *SeenStore) Get(id int) (seenEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.stories[id]
+	if !ok {
+		return seenEntry{}, false
+	}
+	entry.LastSeen = time.Now().Unix()
+	s.stories[id] = entry
+	return entry, true
+}
+
+// Mark records the version of a story that was published and its feeds
+func (s *SeenStore) Mark(story *Story, feeds []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stories[story.ID] = seenEntry{
+		LastSeen:    time.Now().Unix(),
+		Title:       story.Title,
+		URL:         story.URL,
+		Score:       story.Score,
+		Descendants: story.Descendants,
+		Feeds:       feeds,
+	}
+}
+
+// Remove forgets a story, e.g. after its deletion has been published
+func (s *SeenStore) Remove(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.stories, id)
+}
+
+// Len returns the number of tracked IDs
+func (s *SeenStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.stories)
+}
+
+// Evict drops IDs not seen within the TTL and returns how many were removed
+func (s *SeenStore) Evict() int {
+	if s.ttl <= 0 {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := time.Now().Add(-s.ttl).Unix()
+	evicted := 0
+	for id, entry := range s.stories {
+		if entry.LastSeen < cutoff {
+			delete(s.stories, id)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// Save writes the store to disk atomically (write to temp file, then rename)
+func (s *SeenStore) Save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.Lock()
+	data, err := json.Marshal(s.stories)
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal seen store: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".seen-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write seen store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write seen store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace seen store: %w", err)
+	}
+	return nil
+}
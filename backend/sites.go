@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	lobstersURL = "https://lobste.rs/hottest.json"
+	redditURL   = "https://www.reddit.com/r/%s/hot.json?limit=%d"
+	devtoURL    = "https://dev.to/api/articles?per_page=%d"
+)
+
+// lobstersSource polls the Lobsters hottest list
+type lobstersSource struct {
+	sourceInfo
+	scraper *Scraper
+}
+
+type lobstersStory struct {
+	ShortID      string          `json:"short_id"`
+	CreatedAt    time.Time       `json:"created_at"`
+	Title        string          `json:"title"`
+	URL          string          `json:"url"`
+	Score        int             `json:"score"`
+	CommentCount int             `json:"comment_count"`
+	CommentsURL  string          `json:"comments_url"`
+	Submitter    json.RawMessage `json:"submitter_user"`
+}
+
+// submitter handles both API shapes: a bare username or a user object
+func (l lobstersStory) submitter() string {
+	var name string
+	if json.Unmarshal(l.Submitter, &name) == nil {
+		return name
+	}
+	var user struct {
+		Username string `json:"username"`
+	}
+	json.Unmarshal(l.Submitter, &user)
+	return user.Username
+}
+
+func (l *lobstersSource) Fetch() ([]*Story, error) {
+	var items []lobstersStory
+	if err := l.scraper.getJSON(lobstersURL, &items); err != nil {
+		return nil, err
+	}
+
+	stories := make([]*Story, 0, len(items))
+	for _, item := range items {
+		if len(stories) == l.storiesToFetch {
+			break
+		}
+		storyURL := item.URL
+		if storyURL == "" {
+			storyURL = item.CommentsURL
+		}
+		stories = append(stories, &Story{
+			ID:          syntheticID("lobste.rs", item.ShortID),
+			Title:       item.Title,
+			URL:         storyURL,
+			By:          item.submitter(),
+			Score:       item.Score,
+			Time:        item.CreatedAt.Unix(),
+			Type:        "story",
+			Descendants: item.CommentCount,
+			Site:        "lobste.rs",
+		})
+	}
+	return stories, nil
+}
+
+// redditSource polls the hot listing of a subreddit
+type redditSource struct {
+	sourceInfo
+	scraper   *Scraper
+	subreddit string
+}
+
+type redditListing struct {
+	Data struct {
+		Children []struct {
+			Data struct {
+				ID          string  `json:"id"`
+				Title       string  `json:"title"`
+				URL         string  `json:"url"`
+				Author      string  `json:"author"`
+				Score       int     `json:"score"`
+				CreatedUTC  float64 `json:"created_utc"`
+				NumComments int     `json:"num_comments"`
+				Permalink   string  `json:"permalink"`
+				IsSelf      bool    `json:"is_self"`
+				Stickied    bool    `json:"stickied"`
+			} `json:"data"`
+		} `json:"children"`
+	} `json:"data"`
+}
+
+func (r *redditSource) Fetch() ([]*Story, error) {
+	var listing redditListing
+	endpoint := fmt.Sprintf(redditURL, url.PathEscape(r.subreddit), r.storiesToFetch)
+	if err := r.scraper.getJSON(endpoint, &listing); err != nil {
+		return nil, err
+	}
+
+	stories := make([]*Story, 0, len(listing.Data.Children))
+	for _, child := range listing.Data.Children {
+		post := child.Data
+		// Pinned moderator posts aren't part of the ranking
+		if post.Stickied {
+			continue
+		}
+		storyURL := post.URL
+		if post.IsSelf || storyURL == "" {
+			storyURL = "https://www.reddit.com" + post.Permalink
+		}
+		stories = append(stories, &Story{
+			ID:          syntheticID("reddit.com", post.ID),
+			Title:       post.Title,
+			URL:         storyURL,
+			By:          post.Author,
+			Score:       post.Score,
+			Time:        int64(post.CreatedUTC),
+			Type:        "story",
+			Descendants: post.NumComments,
+			Site:        "reddit.com",
+		})
+	}
+	return stories, nil
+}
+
+// devtoSource polls the dev.to article list, optionally for a single tag
+type devtoSource struct {
+	sourceInfo
+	scraper *Scraper
+	tag     string
+}
+
+type devtoArticle struct {
+	ID                 int       `json:"id"`
+	Title              string    `json:"title"`
+	URL                string    `json:"url"`
+	PublishedTimestamp time.Time `json:"published_timestamp"`
+	Reactions          int       `json:"positive_reactions_count"`
+	CommentsCount      int       `json:"comments_count"`
+	User               struct {
+		Username string `json:"username"`
+	} `json:"user"`
+}
+
+func (d *devtoSource) Fetch() ([]*Story, error) {
+	endpoint := fmt.Sprintf(devtoURL, d.storiesToFetch)
+	if d.tag != "" {
+		endpoint += "&tag=" + url.QueryEscape(d.tag)
+	}
+
+	var articles []devtoArticle
+	if err := d.scraper.getJSON(endpoint, &articles); err != nil {
+		return nil, err
+	}
+
+	stories := make([]*Story, 0, len(articles))
+	for _, a := range articles {
+		stories = append(stories, &Story{
+			ID:          syntheticID("dev.to", strconv.Itoa(a.ID)),
+			Title:       a.Title,
+			URL:         a.URL,
+			By:          a.User.Username,
+			Score:       a.Reactions,
+			Time:        a.PublishedTimestamp.Unix(),
+			Type:        "story",
+			Descendants: a.CommentsCount,
+			Site:        "dev.to",
+		})
+	}
+	return stories, nil
+}
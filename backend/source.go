@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// Source is a site the scraper collects stories from. Each source is polled
+// on its own interval and its stories are published with Name as their
+// source.
+type Source interface {
+	Name() string
+	PollInterval() time.Duration
+	// Fetch returns the source's current stories, in the order the site ranks them
+	Fetch() ([]*Story, error)
+}
+
+// SourceConfig configures a non-HN source. Zero values for stories_to_fetch
+// and poll_interval_seconds fall back to the scraper-wide settings.
+type SourceConfig struct {
+	Type                string `yaml:"type"`      // lobsters, reddit, devto or rss
+	Name                string `yaml:"name"`      // defaults to the type (plus subreddit/tag)
+	URL                 string `yaml:"url"`       // rss: the feed URL
+	Subreddit           string `yaml:"subreddit"` // reddit: subreddit without the r/ prefix
+	Tag                 string `yaml:"tag"`       // devto: optional tag
+	StoriesToFetch      int    `yaml:"stories_to_fetch"`
+	PollIntervalSeconds int    `yaml:"poll_interval_seconds"`
+}
+
+// sourceInfo holds the settings every source shares
+type sourceInfo struct {
+	name           string
+	storiesToFetch int
+	pollInterval   time.Duration
+}
+
+func (i sourceInfo) Name() string                { return i.name }
+func (i sourceInfo) PollInterval() time.Duration { return i.pollInterval }
+
+// newSourceInfo applies the scraper-wide defaults to per-source settings
+func newSourceInfo(name string, storiesToFetch, pollIntervalSeconds int, cfg ScraperConfig) sourceInfo {
+	if storiesToFetch <= 0 {
+		storiesToFetch = cfg.StoriesToFetch
+	}
+	if storiesToFetch <= 0 {
+		storiesToFetch = defaultStoriesToFetch
+	}
+	if pollIntervalSeconds <= 0 {
+		pollIntervalSeconds = cfg.PollIntervalSeconds
+	}
+	if pollIntervalSeconds <= 0 {
+		pollIntervalSeconds = defaultPollIntervalSeconds
+	}
+	return sourceInfo{
+		name:           name,
+		storiesToFetch: storiesToFetch,
+		pollInterval:   time.Duration(pollIntervalSeconds) * time.Second,
+	}
+}
+
+// newSources builds the Hacker News feeds followed by the configured sources
+func newSources(s *Scraper, cfg ScraperConfig) ([]Source, error) {
+	sources, err := newHNSources(s, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sc := range cfg.Sources {
+		src, err := newSource(s, sc, cfg)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, src)
+	}
+
+	names := make(map[string]bool)
+	for _, src := range sources {
+		if names[src.Name()] {
+			return nil, fmt.Errorf("source %q configured more than once", src.Name())
+		}
+		names[src.Name()] = true
+	}
+	return sources, nil
+}
+
+func newSource(s *Scraper, sc SourceConfig, cfg ScraperConfig) (Source, error) {
+	name := sc.Name
+	switch sc.Type {
+	case "lobsters":
+		if name == "" {
+			name = "lobsters"
+		}
+		return &lobstersSource{
+			sourceInfo: newSourceInfo(name, sc.StoriesToFetch, sc.PollIntervalSeconds, cfg),
+			scraper:    s,
+		}, nil
+	case "reddit":
+		if sc.Subreddit == "" {
+			return nil, fmt.Errorf("reddit source %q needs a subreddit", sc.Name)
+		}
+		if name == "" {
+			name = "reddit-" + sc.Subreddit
+		}
+		return &redditSource{
+			sourceInfo: newSourceInfo(name, sc.StoriesToFetch, sc.PollIntervalSeconds, cfg),
+			scraper:    s,
+			subreddit:  sc.Subreddit,
+		}, nil
+	case "devto":
+		if name == "" {
+			name = "devto"
+			if sc.Tag != "" {
+				name += "-" + sc.Tag
+			}
+		}
+		return &devtoSource{
+			sourceInfo: newSourceInfo(name, sc.StoriesToFetch, sc.PollIntervalSeconds, cfg),
+			scraper:    s,
+			tag:        sc.Tag,
+		}, nil
+	case "rss":
+		if sc.URL == "" {
+			return nil, fmt.Errorf("rss source %q needs a url", sc.Name)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("rss source %s needs a name", sc.URL)
+		}
+		return &rssSource{
+			sourceInfo: newSourceInfo(name, sc.StoriesToFetch, sc.PollIntervalSeconds, cfg),
+			scraper:    s,
+			url:        sc.URL,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown source type %q (want lobsters, reddit, devto or rss)", sc.Type)
+	}
+}
+
+// syntheticID derives a stable story ID from a site and the site's own
+// identifier for an item. IDs fall in [2^52, 2^53): above any Hacker News item
+// ID, and still exact when the frontend parses them as JavaScript numbers.
+func syntheticID(site, key string) int {
+	h := fnv.New64a()
+	h.Write([]byte(site))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return int(1<<52 | h.Sum64()&(1<<52-1))
+}
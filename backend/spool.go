@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSpoolDrainIntervalSeconds = 30
+
+	// spoolDrainBatch is how many spooled messages are replayed at a time
+	spoolDrainBatch = 100
+)
+
+// Spool metrics, served on the metrics endpoint (see MetricsConfig)
+var (
+	spoolDepth    = expvar.NewInt("spool_depth")
+	spoolWritten  = expvar.NewInt("spool_written_total")
+	spoolReplayed = expvar.NewInt("spool_replayed_total")
+)
+
+// SpoolConfig enables the on-disk spool for messages that fail to publish
+type SpoolConfig struct {
+	// Dir holds spooled messages; empty disables the spool
+	Dir string `yaml:"dir"`
+	// DrainIntervalSeconds is how often spooled messages are replayed (default 30)
+	DrainIntervalSeconds int `yaml:"drain_interval_seconds"`
+}
+
+// Spool is a write-ahead directory of messages the publisher failed to
+// deliver. Each message is synced to its own file before the story is
+// treated as published, so it survives broker outages and restarts. Files
+// are named so that they sort in the order they were spooled.
+type Spool struct {
+	mu   sync.Mutex
+	dir  string
+	seq  uint64
+	keys map[string]int // spooled messages per key
+}
+
+// NewSpool opens the spool directory, creating it if needed, and counts the
+// messages left over from a previous run
+func NewSpool(dir string) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	sp := &Spool{dir: dir, keys: make(map[string]int)}
+	names, err := sp.list()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		msg, err := sp.read(name)
+		if err != nil {
+			return nil, err
+		}
+		sp.keys[msg.Key]++
+	}
+	spoolDepth.Set(int64(len(names)))
+
+	if len(names) > 0 {
+		fmt.Printf("[SPOOL] %d messages waiting in %s\n", len(names), dir)
+	}
+	return sp, nil
+}
+
+// Add writes a message to the spool
+func (sp *Spool) Add(msg Message) error {
+	data, err := json.Marshal(jsonLine{Key: msg.Key, Headers: msg.Headers, Value: msg.Value})
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	sp.seq++
+	name := fmt.Sprintf("%020d-%06d.json", time.Now().UnixNano(), sp.seq%1000000)
+
+	tmp, err := os.CreateTemp(sp.dir, ".spool-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create spool file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync spool file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(sp.dir, name)); err != nil {
+		return fmt.Errorf("failed to move spool file: %w", err)
+	}
+
+	sp.keys[msg.Key]++
+	spoolDepth.Add(1)
+	spoolWritten.Add(1)
+	return nil
+}
+
+// Holds reports whether a message with this key is still spooled. Newer
+// messages for the key are spooled behind it so they are delivered in order.
+func (sp *Spool) Holds(key string) bool {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return sp.keys[key] > 0
+}
+
+// Depth returns the number of spooled messages
+func (sp *Spool) Depth() int64 {
+	return spoolDepth.Value()
+}
+
+// Drain replays spooled messages oldest first, removing each one the
+// publisher delivers. It stops at the first batch with a failure, since the
+// broker is most likely still unreachable, and returns how many were replayed.
+func (sp *Spool) Drain(ctx context.Context, publisher Publisher) (int, error) {
+	names, err := sp.list()
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for start := 0; start < len(names); start += spoolDrainBatch {
+		if ctx.Err() != nil {
+			return replayed, nil
+		}
+		batch := names[start:min(start+spoolDrainBatch, len(names))]
+
+		var (
+			wg       sync.WaitGroup
+			mu       sync.Mutex
+			firstErr error
+		)
+		for _, name := range batch {
+			msg, err := sp.read(name)
+			if err != nil {
+				return replayed, err
+			}
+
+			wg.Add(1)
+			publisher.Publish(ctx, msg, func(err error) {
+				defer wg.Done()
+				if err == nil {
+					err = sp.remove(name, msg.Key)
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+					return
+				}
+				replayed++
+			})
+		}
+		wg.Wait()
+
+		if firstErr != nil {
+			return replayed, firstErr
+		}
+	}
+	return replayed, nil
+}
+
+// list returns the spooled message files, oldest first
+func (sp *Spool) list() ([]string, error) {
+	entries, err := os.ReadDir(sp.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (sp *Spool) read(name string) (Message, error) {
+	data, err := os.ReadFile(filepath.Join(sp.dir, name))
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to read spool file %s: %w", name, err)
+	}
+
+	var line jsonLine
+	if err := json.Unmarshal(data, &line); err != nil {
+		return Message{}, fmt.Errorf("failed to parse spool file %s: %w", name, err)
+	}
+	return Message{Key: line.Key, Value: line.Value, Headers: line.Headers}, nil
+}
+
+func (sp *Spool) remove(name, key string) error {
+	if err := os.Remove(filepath.Join(sp.dir, name)); err != nil {
+		return fmt.Errorf("failed to remove spool file %s: %w", name, err)
+	}
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if sp.keys[key]--; sp.keys[key] <= 0 {
+		delete(sp.keys, key)
+	}
+	spoolDepth.Add(-1)
+	spoolReplayed.Add(1)
+	return nil
+}
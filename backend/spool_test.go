@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errBrokerDown = errors.New("broker down")
+
+func spoolFiles(t *testing.T, dir string) int {
+	t.Helper()
+	sp := &Spool{dir: dir}
+	names, err := sp.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(names)
+}
+
+func TestSpoolDrainStopsAtFailingBatch(t *testing.T) {
+	dir := t.TempDir()
+	sp, err := NewSpool(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const total = spoolDrainBatch + 50
+	for i := 1; i <= total; i++ {
+		if err := sp.Add(Message{Key: strconv.Itoa(i), Value: []byte(`{}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Message 50 fails, so the first batch is replayed except for it and the
+	// second batch is not attempted
+	publisher := &fakePublisher{fail: func(msg Message) error {
+		if msg.Key == "50" {
+			return errBrokerDown
+		}
+		return nil
+	}}
+	replayed, err := sp.Drain(context.Background(), publisher)
+	if !errors.Is(err, errBrokerDown) {
+		t.Fatalf("Drain error %v, want %v", err, errBrokerDown)
+	}
+	if want := spoolDrainBatch - 1; replayed != want || len(publisher.published()) != want {
+		t.Errorf("replayed %d, published %d, want %d", replayed, len(publisher.published()), want)
+	}
+	remaining := total - (spoolDrainBatch - 1)
+	if sp.Depth() != int64(remaining) || spoolFiles(t, dir) != remaining {
+		t.Errorf("depth %d with %d files, want %d", sp.Depth(), spoolFiles(t, dir), remaining)
+	}
+	if !sp.Holds("50") || sp.Holds("49") || !sp.Holds(strconv.Itoa(total)) {
+		t.Error("the spool should hold the failed message and the unattempted batch only")
+	}
+
+	// Once the broker is back the rest are replayed, oldest first
+	publisher = &fakePublisher{}
+	replayed, err = sp.Drain(context.Background(), publisher)
+	if err != nil || replayed != remaining {
+		t.Fatalf("second Drain = %d, %v; want %d", replayed, err, remaining)
+	}
+	keys := publisher.published()
+	if keys[0] != "50" || keys[1] != strconv.Itoa(spoolDrainBatch+1) || keys[len(keys)-1] != strconv.Itoa(total) {
+		t.Errorf("replayed %s ... %s, want 50, %d ... %d", keys[:2], keys[len(keys)-1], spoolDrainBatch+1, total)
+	}
+	if sp.Depth() != 0 || spoolFiles(t, dir) != 0 {
+		t.Errorf("depth %d with %d files after draining, want 0", sp.Depth(), spoolFiles(t, dir))
+	}
+}
+
+// Once an event for a story is spooled, later events for it are spooled
+// behind it even when the broker is back, so they are never delivered out of
+// order
+func TestSpoolHoldsKeyOrder(t *testing.T) {
+	sp, err := NewSpool(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher := &fakePublisher{fail: func(Message) error { return errBrokerDown }}
+	s := &Scraper{
+		publisher:     publisher,
+		spool:         sp,
+		schemaVersion: schemaVersionEnvelope,
+		ctx:           context.Background(),
+	}
+	publish := func(id int, title, event string) {
+		t.Helper()
+		req := publishRequest{
+			story:     &Story{ID: id, Title: title},
+			source:    "top",
+			feeds:     []string{"top"},
+			event:     event,
+			fetchedAt: time.Now(),
+		}
+		s.publishStory(req, func(err error) {
+			if err != nil {
+				t.Errorf("%s: %v", title, err)
+			}
+		})
+	}
+
+	publish(1, "Created while the broker is down", eventCreated)
+	publisher.fail = nil
+	publish(1, "Updated after it is back", eventUpdated)
+	publish(2, "Another story", eventCreated)
+
+	if got := strings.Join(publisher.published(), ","); got != "2" {
+		t.Errorf("published %s before draining, want only story 2", got)
+	}
+	if sp.Depth() != 2 || !sp.Holds("1") {
+		t.Fatalf("depth %d, holds story 1 %v; want both of its events spooled", sp.Depth(), sp.Holds("1"))
+	}
+
+	if _, err := sp.Drain(context.Background(), publisher); err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	for _, msg := range publisher.msgs[1:] {
+		var env Envelope
+		if err := json.Unmarshal(msg.Value, &env); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, env.Event)
+	}
+	if got := strings.Join(events, ","); got != "created,updated" {
+		t.Errorf("story 1 events replayed as %s, want created,updated", got)
+	}
+	if sp.Holds("1") {
+		t.Error("story 1 is still held after draining")
+	}
+}
+
+func TestSpoolRecountsOnRestart(t *testing.T) {
+	dir := t.TempDir()
+	sp, err := NewSpool(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"1", "1", "2"} {
+		if err := sp.Add(Message{Key: key, Value: []byte(`{}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A temp file left by a crash mid-write is not a message
+	if err := os.WriteFile(filepath.Join(dir, ".spool-123.tmp"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	spoolDepth.Set(0) // as in a new process
+	sp, err = NewSpool(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sp.Depth() != 3 {
+		t.Errorf("depth %d after reopening, want 3", sp.Depth())
+	}
+	if !sp.Holds("1") || !sp.Holds("2") || sp.Holds("3") {
+		t.Errorf("holds 1: %v, 2: %v, 3: %v; want true, true, false", sp.Holds("1"), sp.Holds("2"), sp.Holds("3"))
+	}
+
+	// Keys stay held until their last message is replayed
+	publisher := &fakePublisher{fail: func(msg Message) error {
+		if msg.Key == "2" {
+			return errBrokerDown
+		}
+		return nil
+	}}
+	sp.Drain(context.Background(), publisher)
+	if sp.Holds("1") || !sp.Holds("2") || sp.Depth() != 1 {
+		t.Errorf("after a partial drain: holds 1: %v, 2: %v, depth %d", sp.Holds("1"), sp.Holds("2"), sp.Depth())
+	}
+}
@@ -2,22 +2,44 @@ import React, { useState, useEffect } from 'react';
 import './Stories.css';
 import StoryItem from './StoryItem';
 
+const PAGE_SIZE = 50;
+
+// pageUrl adds pagination parameters to a story-api URL
+const pageUrl = (url, cursor) => {
+  const pageURL = new URL(url, window.location.href);
+  pageURL.searchParams.set('limit', PAGE_SIZE);
+  if (cursor) {
+    pageURL.searchParams.set('cursor', cursor);
+  }
+  return pageURL.toString();
+};
+
 function Stories({ api, refreshTrigger }) {
   const [stories, setStories] = useState([]);
+  const [total, setTotal] = useState(0);
+  const [nextCursor, setNextCursor] = useState(null);
   const [loading, setLoading] = useState(false);
   const [error, setError] = useState(null);
 
-  const fetchStories = async () => {
+  // fetchStories loads the first page, or appends the page after cursor
+  const fetchStories = async (cursor) => {
     if (!api) return;
 
     setLoading(true);
     setError(null);
 
     try {
-      const response = await fetch(api.url);
+      const response = await fetch(pageUrl(api.url, cursor));
       if (!response.ok) throw new Error('Failed to fetch stories');
       const data = await response.json();
-      setStories(data || []);
+      // Older story-api instances ignore pagination and return a bare array
+      const page = Array.isArray(data)
+        ? { stories: data, total: data.length, next_cursor: null }
+        : data;
+      const pageStories = page.stories || [];
+      setStories((prev) => (cursor ? [...prev, ...pageStories] : pageStories));
+      setTotal(page.total || 0);
+      setNextCursor(page.next_cursor || null);
     } catch (err) {
       setError(`Failed to load stories from ${api.name}`);
       console.error(err);
@@ -54,6 +76,15 @@ function Stories({ api, refreshTrigger }) {
           ))}
         </ol>
       )}
+      {nextCursor && (
+        <button
+          className="load-more"
+          onClick={() => fetchStories(nextCursor)}
+          disabled={loading}
+        >
+          {loading ? 'Loading...' : `More (${stories.length} of ${total})`}
+        </button>
+      )}
     </div>
   );
 }
@@ -23,6 +23,7 @@ function StoryItem({ story, rank }) {
       <div className="story-meta">
         <span className="score">{story.score} points</span>
         {story.by && <span className="by">by {story.by}</span>}
+        {story.site && <span className="site">on {story.site}</span>}
         <span className="time">{timeAgo}</span>
       </div>
     </li>
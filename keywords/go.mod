@@ -0,0 +1,3 @@
+module github.com/JohnCrickett/top-stories/keywords
+
+go 1.23
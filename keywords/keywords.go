@@ -0,0 +1,103 @@
+// Package keywords compiles the keyword entries that story-api filters and
+// scraper backfills match story titles against, so that a backfill with
+// -keywords publishes exactly what a story-api filter with the same keywords
+// keeps.
+package keywords
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Keyword entries come in three forms, all matched case-insensitively
+// against story titles:
+//
+//	rust        whole words: "rust" matches "Rust 1.80" but not "trust";
+//	            "show hn" matches the words in order, "Show HN: ..." included
+//	"c++"       a phrase matched literally, punctuation and all, at word
+//	            boundaries; runs of spaces match any whitespace. Unquoted
+//	            entries with punctuation, like c++ or .net, are phrases too.
+//	/^ask hn:/  a regular expression (RE2 syntax)
+
+// Compile compiles keyword entries, failing on the first invalid one
+func Compile(entries []string) ([]*regexp.Regexp, error) {
+	keywords := make([]*regexp.Regexp, 0, len(entries))
+	for _, entry := range entries {
+		re, err := compileKeyword(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid keyword %q: %w", entry, err)
+		}
+		keywords = append(keywords, re)
+	}
+	return keywords, nil
+}
+
+func compileKeyword(entry string) (*regexp.Regexp, error) {
+	entry = strings.TrimSpace(entry)
+	switch {
+	case len(entry) >= 2 && strings.HasPrefix(entry, "/") && strings.HasSuffix(entry, "/"):
+		pattern := entry[1 : len(entry)-1]
+		if pattern == "" {
+			return nil, fmt.Errorf("empty regular expression")
+		}
+		return regexp.Compile("(?i)" + pattern)
+	case len(entry) >= 2 && strings.HasPrefix(entry, `"`) && strings.HasSuffix(entry, `"`):
+		return compilePhrase(entry[1 : len(entry)-1])
+	case strings.IndexFunc(entry, func(r rune) bool { return !isWordRune(r) && !unicode.IsSpace(r) }) >= 0:
+		// Splitting into words would drop the punctuation, turning c++
+		// into c, so match it literally
+		return compilePhrase(entry)
+	default:
+		words := splitWords(entry)
+		if len(words) == 0 {
+			return nil, fmt.Errorf("no words to match")
+		}
+		parts := make([]string, len(words))
+		for i, word := range words {
+			parts[i] = regexp.QuoteMeta(word)
+		}
+		return regexp.Compile(atWordBoundaries(strings.Join(words, " "), strings.Join(parts, `[^\pL\pN]+`)))
+	}
+}
+
+// compilePhrase matches text literally at word boundaries, with runs of
+// spaces matching any whitespace
+func compilePhrase(text string) (*regexp.Regexp, error) {
+	phrase := strings.Fields(text)
+	if len(phrase) == 0 {
+		return nil, fmt.Errorf("empty phrase")
+	}
+	parts := make([]string, len(phrase))
+	for i, word := range phrase {
+		parts[i] = regexp.QuoteMeta(word)
+	}
+	return regexp.Compile(atWordBoundaries(strings.Join(phrase, " "), strings.Join(parts, `\s+`)))
+}
+
+// atWordBoundaries makes a case-insensitive pattern for text match only where
+// text's first and last characters, if letters or digits, aren't preceded or
+// followed by another letter or digit
+func atWordBoundaries(text, pattern string) string {
+	runes := []rune(text)
+	if isWordRune(runes[0]) {
+		pattern = `(?:^|[^\pL\pN])` + pattern
+	}
+	if isWordRune(runes[len(runes)-1]) {
+		pattern += `(?:[^\pL\pN]|$)`
+	}
+	return "(?i)" + pattern
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// splitWords lowercases text and splits it into runs of letters and digits,
+// as story-api's search index does
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !isWordRune(r)
+	})
+}
@@ -0,0 +1,52 @@
+package keywords
+
+import "testing"
+
+func TestCompileKeywordMatches(t *testing.T) {
+	tests := []struct {
+		keyword string
+		title   string
+		want    bool
+	}{
+		{"go", "Go 1.23 released", true},
+		{"go", "Google announces", false},
+		{"go", "Ten years ago", false},
+		{"rust", "Why we trust Rust", true},
+		{"rust", "Building trust", false},
+		{"show hn", "Show HN: A tiny database", true},
+		{"show hn", "Showing HN readers", false},
+		{"zürich", "Zürich meetup", true},
+		{"zürich", "Zürichsee", false},
+		{`"c++"`, "C++ 26 is out", true},
+		{`"c++"`, "Learning cc++", false},
+		{`"node.js"`, "Node.js 22", true},
+		{`"node.js"`, "nodexjs", false},
+		{`"ask  hn"`, "Ask\tHN: why?", true},
+		{"c++", "C++ 26 is out", true},
+		{"c++", "C is great", false},
+		{"c#", "C# 13 features", true},
+		{"c#", "C is great", false},
+		{".net", "What's new in .NET 9", true},
+		{".net", "The net effect", false},
+		{"/^ask hn:/", "Ask HN: why?", true},
+		{"/^ask hn:/", "Tell HN: ask hn: is dead", false},
+		{`/\bk8s\b|kubernetes/`, "Running K8s at home", true},
+	}
+	for _, tt := range tests {
+		re, err := compileKeyword(tt.keyword)
+		if err != nil {
+			t.Fatalf("compileKeyword(%q): %v", tt.keyword, err)
+		}
+		if got := re.MatchString(tt.title); got != tt.want {
+			t.Errorf("keyword %q on %q = %v, want %v", tt.keyword, tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, keyword := range []string{"/[/", "//", `""`, "   "} {
+		if _, err := Compile([]string{"rust", keyword}); err == nil {
+			t.Errorf("compileKeywords(%q) succeeded, want an error", keyword)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxAdminBodyBytes caps the size of admin request bodies
+const maxAdminBodyBytes = 1 << 20
+
+// FilterResponse is the body of GET and PUT /admin/filter responses
+type FilterResponse struct {
+	Feed   string       `json:"feed"`
+	Filter FilterConfig `json:"filter"`
+	// Reapplied is set when PUT re-applied the filter to stored stories
+	Reapplied bool `json:"reapplied,omitempty"`
+	Kept      int  `json:"kept,omitempty"`
+	Removed   int  `json:"removed,omitempty"`
+}
+
+// requireAdmin only lets requests through that carry the configured admin
+// token as a bearer token. Without a configured token the endpoints are
+// disabled.
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := s.config.API.AdminToken
+		if token == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="story-api admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleAdminFilter returns a feed's active filter (GET) or replaces it (PUT);
+// /admin/filter is the default feed. Consumption continues throughout: the
+// next message is checked against the new filter. With ?reapply=true, stored
+// stories that no feed wants any more are removed; stories the old filter
+// rejected are not brought back.
+func (s *Server) handleAdminFilter(w http.ResponseWriter, r *http.Request) {
+	feed := s.feedFromPath(r)
+	if feed == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, FilterResponse{Feed: feed.name, Filter: feed.filter.Load().config})
+	case http.MethodPut:
+		var cfg FilterConfig
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminBodyBytes))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&cfg); err != nil {
+			http.Error(w, fmt.Sprintf("invalid filter: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		reapply := false
+		if v := r.URL.Query().Get("reapply"); v != "" {
+			var err error
+			if reapply, err = strconv.ParseBool(v); err != nil {
+				http.Error(w, "reapply must be true or false", http.StatusBadRequest)
+				return
+			}
+		}
+
+		filter, err := NewStoryFilter(cfg)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid filter: %v", err), http.StatusBadRequest)
+			return
+		}
+		feed.filter.Store(filter)
+		fmt.Printf("[ADMIN] Filter replaced for feed %s\n", feed.name)
+		logFilter(feed.name, filter)
+
+		resp := FilterResponse{Feed: feed.name, Filter: cfg, Reapplied: reapply}
+		if reapply {
+			resp.Kept, resp.Removed, err = applyFilter(s.store, s.wanted)
+			if err != nil {
+				fmt.Printf("[ERROR] Failed to re-apply filter: %v\n", err)
+				http.Error(w, "filter replaced, but re-applying it failed", http.StatusInternalServerError)
+				return
+			}
+			fmt.Printf("[ADMIN] Re-applied filter: kept %d stories, removed %d\n", resp.Kept, resp.Removed)
+		}
+		writeJSON(w, resp)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
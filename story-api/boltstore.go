@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var storiesBucket = []byte("stories")
+
+// BoltStore keeps stories in an embedded bbolt database file, so an instance
+// serves its stories straight away after a restart. Stories are stored as JSON
+// under their big-endian ID.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens or creates the database at path. Only one process can
+// have the file open at a time.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open story database %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(storiesBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize story database: %w", err)
+	}
+
+	fmt.Printf("[DEBUG] Storing stories in %s\n", path)
+	return &BoltStore{db: db}, nil
+}
+
+func storyKey(id int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
+
+func (s *BoltStore) AddStory(story *Story) (bool, error) {
+	data, err := json.Marshal(story)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal story %d: %w", story.ID, err)
+	}
+
+	var existed bool
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(storiesBucket)
+		key := storyKey(story.ID)
+		existed = b.Get(key) != nil
+		return b.Put(key, data)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to store story %d: %w", story.ID, err)
+	}
+	return existed, nil
+}
+
+// RemoveStory checks for the story in a read transaction first: a write
+// transaction fsyncs even when it changes nothing, and the consumer removes
+// every story a filter rejects, most of which were never stored
+func (s *BoltStore) RemoveStory(id int) (bool, error) {
+	var existed bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		existed = tx.Bucket(storiesBucket).Get(storyKey(id)) != nil
+		return nil
+	})
+	if err != nil || !existed {
+		return false, err
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(storiesBucket)
+		key := storyKey(id)
+		if existed = b.Get(key) != nil; !existed {
+			return nil
+		}
+		return b.Delete(key)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to remove story %d: %w", id, err)
+	}
+	return existed, nil
+}
+
+func (s *BoltStore) GetAllStories() ([]*Story, error) {
+	stories := make([]*Story, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(storiesBucket).ForEach(func(k, v []byte) error {
+			var story Story
+			if err := json.Unmarshal(v, &story); err != nil {
+				return fmt.Errorf("failed to decode story %d: %w", binary.BigEndian.Uint64(k), err)
+			}
+			stories = append(stories, &story)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stories: %w", err)
+	}
+	return stories, nil
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
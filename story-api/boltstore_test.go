@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBoltStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stories.db")
+	store, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if existed, err := store.AddStory(&Story{ID: 1, Title: "First"}); existed || err != nil {
+		t.Fatalf("AddStory(new) = %v, %v", existed, err)
+	}
+	if existed, err := store.AddStory(&Story{ID: 1, Title: "First, edited"}); !existed || err != nil {
+		t.Fatalf("AddStory(existing) = %v, %v", existed, err)
+	}
+	store.AddStory(&Story{ID: 2, Title: "Second"})
+
+	// Removing a story that isn't stored must not commit a write transaction
+	before := store.db.Stats()
+	if existed, err := store.RemoveStory(3); existed || err != nil {
+		t.Fatalf("RemoveStory(missing) = %v, %v", existed, err)
+	}
+	after := store.db.Stats()
+	if writes := after.TxStats.GetWrite() - before.TxStats.GetWrite(); writes != 0 {
+		t.Errorf("RemoveStory(missing) wrote %d pages", writes)
+	}
+	if existed, err := store.RemoveStory(2); !existed || err != nil {
+		t.Fatalf("RemoveStory(stored) = %v, %v", existed, err)
+	}
+
+	// Stories survive reopening
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	store, err = NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	stories, err := store.GetAllStories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stories) != 1 || stories[0].ID != 1 || stories[0].Title != "First, edited" {
+		t.Errorf("after reopening: %+v", stories)
+	}
+}
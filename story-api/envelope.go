@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Message schema versions produced by the scraper. Version 1 is a bare Story;
+// version 2 wraps it in an Envelope. Newer versions are decoded as envelopes,
+// ignoring fields this build doesn't know about.
+const (
+	schemaVersionLegacy   = 1
+	schemaVersionEnvelope = 2
+)
+
+const (
+	eventCreated = "created"
+	eventUpdated = "updated"
+	eventDeleted = "deleted"
+)
+
+// Envelope is the version 2 message format
+type Envelope struct {
+	SchemaVersion int       `json:"schema_version"`
+	Event         string    `json:"event"`
+	Source        string    `json:"source"`
+	Feeds         []string  `json:"feeds"`
+	FetchedAt     time.Time `json:"fetched_at"`
+	Payload       *Story    `json:"payload"`
+}
+
+// StoryEvent is a decoded message of any schema version
+type StoryEvent struct {
+	SchemaVersion int
+	Event         string
+	Source        string
+	Feeds         []string // every feed the story has appeared in
+	FetchedAt     time.Time
+	Story         *Story
+}
+
+// decodeMessage decodes a Kafka message. The version comes from the
+// schema_version header, or is inferred from the value for producers that
+// don't set headers.
+func decodeMessage(msg kafka.Message) (*StoryEvent, error) {
+	headers := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+
+	version := 0
+	if v, ok := headers["schema_version"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema_version header %q", v)
+		}
+		version = n
+	}
+	if version == 0 {
+		var probe struct {
+			SchemaVersion int             `json:"schema_version"`
+			Payload       json.RawMessage `json:"payload"`
+		}
+		if err := json.Unmarshal(msg.Value, &probe); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal message: %w", err)
+		}
+		version = schemaVersionLegacy
+		if probe.Payload != nil {
+			version = max(probe.SchemaVersion, schemaVersionEnvelope)
+		}
+	}
+
+	event := &StoryEvent{SchemaVersion: version}
+	if version <= schemaVersionLegacy {
+		var story Story
+		if err := json.Unmarshal(msg.Value, &story); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal story: %w", err)
+		}
+		event.Story = &story
+		event.Event = headers["event"]
+		event.Source = headers["source"]
+		if feeds := headers["feeds"]; feeds != "" {
+			event.Feeds = strings.Split(feeds, ",")
+		}
+	} else {
+		var env Envelope
+		if err := json.Unmarshal(msg.Value, &env); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal envelope: %w", err)
+		}
+		if env.Payload == nil {
+			return nil, fmt.Errorf("envelope has no payload")
+		}
+		event.Story = env.Payload
+		event.Event = env.Event
+		event.Source = env.Source
+		event.Feeds = env.Feeds
+		event.FetchedAt = env.FetchedAt
+	}
+
+	// Fill in whatever older producers left out. Producers from before the
+	// envelope keyed messages by feed name rather than story ID, and producers
+	// from before feed sets only name the feed that published the story.
+	if event.Event == "" {
+		event.Event = eventCreated
+	}
+	if event.Source == "" {
+		event.Source = string(msg.Key)
+	}
+	if len(event.Feeds) == 0 {
+		event.Feeds = []string{event.Source}
+	}
+	event.Story.Feed = event.Source
+	event.Story.Feeds = event.Feeds
+	return event, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func kafkaHeaders(kv ...string) []kafka.Header {
+	var headers []kafka.Header
+	for i := 0; i < len(kv); i += 2 {
+		headers = append(headers, kafka.Header{Key: kv[i], Value: []byte(kv[i+1])})
+	}
+	return headers
+}
+
+// TestDecodeMessage covers every producer a consumer can meet during a
+// rollout, so producers and consumers can be deployed in either order
+func TestDecodeMessage(t *testing.T) {
+	const story = `{"id":42,"title":"Rust 2.0","score":10,"type":"story"}`
+	fetchedAt := time.Date(2025, 1, 14, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		msg     kafka.Message
+		version int
+		event   string
+		source  string
+		feeds   string
+		fetched time.Time
+	}{
+		{
+			name:    "v1 bare story keyed by feed, no headers",
+			msg:     kafka.Message{Key: []byte("top"), Value: []byte(story)},
+			version: schemaVersionLegacy,
+			event:   eventCreated,
+			source:  "top",
+			feeds:   "top",
+		},
+		{
+			name: "v1 story with headers",
+			msg: kafka.Message{
+				Key:     []byte("42"),
+				Value:   []byte(story),
+				Headers: kafkaHeaders("schema_version", "1", "event", "updated", "source", "top", "feeds", "new,top"),
+			},
+			version: schemaVersionLegacy,
+			event:   eventUpdated,
+			source:  "top",
+			feeds:   "new,top",
+		},
+		{
+			name: "v2 envelope with headers",
+			msg: kafka.Message{
+				Key:     []byte("42"),
+				Value:   []byte(`{"schema_version":2,"event":"created","source":"new","feeds":["new"],"fetched_at":"2025-01-14T09:30:00Z","payload":` + story + `}`),
+				Headers: kafkaHeaders("schema_version", "2", "event", "created", "source", "new", "feeds", "new"),
+			},
+			version: schemaVersionEnvelope,
+			event:   eventCreated,
+			source:  "new",
+			feeds:   "new",
+			fetched: fetchedAt,
+		},
+		{
+			name: "v2 envelope without headers or feeds",
+			msg: kafka.Message{
+				Key:   []byte("42"),
+				Value: []byte(`{"schema_version":2,"event":"updated","source":"lobsters","fetched_at":"2025-01-14T09:30:00Z","payload":` + story + `}`),
+			},
+			version: schemaVersionEnvelope,
+			event:   eventUpdated,
+			source:  "lobsters",
+			feeds:   "lobsters",
+			fetched: fetchedAt,
+		},
+		{
+			name: "future v3 envelope with fields this build doesn't know",
+			msg: kafka.Message{
+				Key:     []byte("42"),
+				Value:   []byte(`{"schema_version":3,"event":"created","source":"top","feeds":["top"],"trace_id":"abc","payload":` + story + `}`),
+				Headers: kafkaHeaders("schema_version", "3"),
+			},
+			version: 3,
+			event:   eventCreated,
+			source:  "top",
+			feeds:   "top",
+		},
+		{
+			name: "future v3 envelope without headers",
+			msg: kafka.Message{
+				Key:   []byte("42"),
+				Value: []byte(`{"schema_version":3,"source":"best","payload":` + story + `}`),
+			},
+			version: 3,
+			event:   eventCreated,
+			source:  "best",
+			feeds:   "best",
+		},
+		{
+			name: "deleted event",
+			msg: kafka.Message{
+				Key:     []byte("42"),
+				Value:   []byte(`{"schema_version":2,"event":"deleted","source":"top","feeds":["new","top"],"payload":` + story + `}`),
+				Headers: kafkaHeaders("schema_version", "2", "event", "deleted"),
+			},
+			version: schemaVersionEnvelope,
+			event:   eventDeleted,
+			source:  "top",
+			feeds:   "new,top",
+		},
+	}
+	for _, tt := range tests {
+		event, err := decodeMessage(tt.msg)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if event.SchemaVersion != tt.version || event.Event != tt.event || event.Source != tt.source ||
+			strings.Join(event.Feeds, ",") != tt.feeds || !event.FetchedAt.Equal(tt.fetched) {
+			t.Errorf("%s: got version %d, event %q, source %q, feeds %v, fetched at %v",
+				tt.name, event.SchemaVersion, event.Event, event.Source, event.Feeds, event.FetchedAt)
+		}
+		s := event.Story
+		if s.ID != 42 || s.Title != "Rust 2.0" || s.Score != 10 || s.Type != "story" {
+			t.Errorf("%s: story %+v", tt.name, *s)
+		}
+		if s.Feed != tt.source || strings.Join(s.Feeds, ",") != tt.feeds {
+			t.Errorf("%s: story feed %q, feeds %v", tt.name, s.Feed, s.Feeds)
+		}
+	}
+}
+
+func TestDecodeMessageErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  kafka.Message
+		want string
+	}{
+		{
+			name: "bad schema_version header",
+			msg:  kafka.Message{Value: []byte(`{"id":1}`), Headers: kafkaHeaders("schema_version", "two")},
+			want: `invalid schema_version header "two"`,
+		},
+		{
+			name: "not JSON",
+			msg:  kafka.Message{Value: []byte(`not json`)},
+			want: "failed to unmarshal message",
+		},
+		{
+			name: "envelope without a payload",
+			msg:  kafka.Message{Value: []byte(`{"schema_version":2}`), Headers: kafkaHeaders("schema_version", "2")},
+			want: "envelope has no payload",
+		},
+	}
+	for _, tt := range tests {
+		_, err := decodeMessage(tt.msg)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync/atomic"
+)
+
+// defaultFeedName is the feed built from the top-level filter config, served
+// at /stories as well as /feeds/default/stories
+const defaultFeedName = "default"
+
+var feedNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// FeedConfig is an additional named feed hosted by this instance
+type FeedConfig struct {
+	Name   string       `yaml:"name"`
+	Filter FilterConfig `yaml:"filter"`
+}
+
+// Feed is a named view of the shared story store: it serves the stored
+// stories its filter matches. The filter can be swapped at runtime.
+type Feed struct {
+	name   string
+	filter atomic.Pointer[StoryFilter]
+}
+
+// newFeeds builds the default feed followed by the configured feeds
+func newFeeds(cfg Config) ([]*Feed, error) {
+	configs := append([]FeedConfig{{Name: defaultFeedName, Filter: cfg.Filter}}, cfg.Feeds...)
+
+	feeds := make([]*Feed, 0, len(configs))
+	seen := make(map[string]bool)
+	for i, fc := range configs {
+		if i > 0 && fc.Name == defaultFeedName {
+			return nil, fmt.Errorf("feed name %q is reserved for the top-level filter", defaultFeedName)
+		}
+		if !feedNamePattern.MatchString(fc.Name) {
+			return nil, fmt.Errorf("invalid feed name %q (use lowercase letters, digits, - and _)", fc.Name)
+		}
+		if seen[fc.Name] {
+			return nil, fmt.Errorf("duplicate feed name %q", fc.Name)
+		}
+		seen[fc.Name] = true
+
+		filter, err := NewStoryFilter(fc.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter for feed %s: %w", fc.Name, err)
+		}
+		feed := &Feed{name: fc.Name}
+		feed.filter.Store(filter)
+		feeds = append(feeds, feed)
+	}
+	return feeds, nil
+}
+
+// defaultFeed returns the feed served at /stories
+func (s *Server) defaultFeed() *Feed {
+	return s.feeds[0]
+}
+
+// feedFromPath returns the feed named in the request path, or nil
+func (s *Server) feedFromPath(r *http.Request) *Feed {
+	name := r.PathValue("name")
+	if name == "" {
+		return s.defaultFeed()
+	}
+	for _, feed := range s.feeds {
+		if feed.name == name {
+			return feed
+		}
+	}
+	return nil
+}
+
+// wanted reports whether any feed matches a story. The store holds exactly
+// the stories some feed wants.
+func (s *Server) wanted(story *Story) bool {
+	for _, feed := range s.feeds {
+		if feed.filter.Load().Matches(story) {
+			return true
+		}
+	}
+	return false
+}
+
+// rejections returns, for each feed, the filter clause that rejects a story,
+// or nil if some feed wants it
+func (s *Server) rejections(story *Story) map[string]string {
+	rejectedBy := make(map[string]string, len(s.feeds))
+	for _, feed := range s.feeds {
+		clause := feed.filter.Load().Reject(story)
+		if clause == "" {
+			return nil
+		}
+		rejectedBy[feed.name] = clause
+	}
+	return rejectedBy
+}
+
+// FeedInfo describes a feed in the GET /feeds response
+type FeedInfo struct {
+	Name    string       `json:"name"`
+	Stories string       `json:"stories"`
+	Filter  FilterConfig `json:"filter"`
+}
+
+// handleListFeeds handles GET /feeds
+func (s *Server) handleListFeeds(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	feeds := make([]FeedInfo, 0, len(s.feeds))
+	for _, feed := range s.feeds {
+		feeds = append(feeds, FeedInfo{
+			Name:    feed.name,
+			Stories: "/feeds/" + feed.name + "/stories",
+			Filter:  feed.filter.Load().config,
+		})
+	}
+	writeJSON(w, feeds)
+}
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Filter expressions combine comparisons on story fields with and, or, not
+// and parentheses, e.g.
+//
+//	type in ("story", "show") and (title ~ "rust" or domain = "github.com")
+//	    and not by = "spammer" and score >= 50
+//
+// String fields support = and != (case-insensitive equality), ~ and !~
+// (case-insensitive substring) and in. Numeric fields support =, !=, <, <=,
+// >, >= and in; time also accepts RFC3339 strings.
+
+// Expr is a compiled filter expression
+type Expr struct {
+	source string
+	root   exprNode
+}
+
+// Matches reports whether a story satisfies the expression
+func (e *Expr) Matches(story *Story) bool {
+	return e.root.eval(story)
+}
+
+func (e *Expr) String() string {
+	return e.source
+}
+
+// exprField is a story field that expressions can refer to. A text field
+// with several values (strs) matches =, ~ and in if any value does, and != and
+// !~ if every value does.
+type exprField struct {
+	numeric bool
+	str     func(*Story) string
+	strs    func(*Story) []string
+	num     func(*Story) int64
+}
+
+var exprFields = map[string]exprField{
+	"type":     {str: func(s *Story) string { return s.Type }},
+	"title":    {str: func(s *Story) string { return s.Title }},
+	"by":       {str: func(s *Story) string { return s.By }},
+	"url":      {str: func(s *Story) string { return s.URL }},
+	"domain":   {str: func(s *Story) string { return storyDomain(s.URL) }},
+	"site":     {str: func(s *Story) string { return s.Site }},
+	"feed":     {strs: func(s *Story) []string { return s.feeds() }},
+	"id":       {numeric: true, num: func(s *Story) int64 { return int64(s.ID) }},
+	"score":    {numeric: true, num: func(s *Story) int64 { return int64(s.Score) }},
+	"comments": {numeric: true, num: func(s *Story) int64 { return int64(s.Descendants) }},
+	"time":     {numeric: true, num: func(s *Story) int64 { return s.Time }},
+}
+
+// exprFieldNames lists the fields for error messages
+const exprFieldNames = "type, title, by, url, domain, site, feed, id, score, comments, time"
+
+type exprNode interface {
+	eval(story *Story) bool
+}
+
+type andNode struct{ left, right exprNode }
+type orNode struct{ left, right exprNode }
+type notNode struct{ operand exprNode }
+
+func (n andNode) eval(s *Story) bool { return n.left.eval(s) && n.right.eval(s) }
+func (n orNode) eval(s *Story) bool  { return n.left.eval(s) || n.right.eval(s) }
+func (n notNode) eval(s *Story) bool { return !n.operand.eval(s) }
+
+// stringCmp compares a string field; values are lowercased at compile time
+type stringCmp struct {
+	field  exprField
+	op     string
+	values []string
+}
+
+func (c stringCmp) eval(s *Story) bool {
+	if c.field.strs == nil {
+		return c.match(c.field.str(s))
+	}
+	negated := c.op == "!=" || c.op == "!~"
+	for _, v := range c.field.strs(s) {
+		if c.match(v) != negated {
+			return !negated
+		}
+	}
+	return negated
+}
+
+func (c stringCmp) match(v string) bool {
+	v = strings.ToLower(v)
+	switch c.op {
+	case "=":
+		return v == c.values[0]
+	case "!=":
+		return v != c.values[0]
+	case "~":
+		return strings.Contains(v, c.values[0])
+	case "!~":
+		return !strings.Contains(v, c.values[0])
+	default: // in
+		for _, want := range c.values {
+			if v == want {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+type numberCmp struct {
+	field  exprField
+	op     string
+	values []int64
+}
+
+func (c numberCmp) eval(s *Story) bool {
+	v := c.field.num(s)
+	switch c.op {
+	case "=":
+		return v == c.values[0]
+	case "!=":
+		return v != c.values[0]
+	case "<":
+		return v < c.values[0]
+	case "<=":
+		return v <= c.values[0]
+	case ">":
+		return v > c.values[0]
+	case ">=":
+		return v >= c.values[0]
+	default: // in
+		for _, want := range c.values {
+			if v == want {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ParseExpr compiles a filter expression. Errors give the column of the
+// offending token.
+func ParseExpr(source string) (*Expr, error) {
+	tokens, err := lexExpr(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, tok.errorf("unexpected %s", tok)
+	}
+	return &Expr{source: source, root: root}, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type exprToken struct {
+	kind tokenKind
+	text string // identifier, operator, number or unquoted string
+	col  int    // 1-based
+}
+
+func (t exprToken) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+func (t exprToken) errorf(format string, args ...any) error {
+	return fmt.Errorf("filter expression: column %d: %s", t.col, fmt.Sprintf(format, args...))
+}
+
+// lexExpr splits an expression into tokens
+func lexExpr(source string) ([]exprToken, error) {
+	var tokens []exprToken
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		col := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, exprToken{kind: tokLParen, text: "(", col: col})
+			i++
+		case r == ')':
+			tokens = append(tokens, exprToken{kind: tokRParen, text: ")", col: col})
+			i++
+		case r == ',':
+			tokens = append(tokens, exprToken{kind: tokComma, text: ",", col: col})
+			i++
+		case r == '"':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				sb.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("filter expression: column %d: unterminated string", col)
+			}
+			tokens = append(tokens, exprToken{kind: tokString, text: sb.String(), col: col})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i + 1
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, exprToken{kind: tokNumber, text: string(runes[i:j]), col: col})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, exprToken{kind: tokIdent, text: string(runes[i:j]), col: col})
+			i = j
+		case strings.ContainsRune("=!<>~", r):
+			op := string(r)
+			if i+1 < len(runes) && strings.ContainsRune("=~", runes[i+1]) && r != '=' && r != '~' {
+				op += string(runes[i+1])
+			}
+			switch op {
+			case "=", "!=", "<", "<=", ">", ">=", "~", "!~":
+			default:
+				return nil, fmt.Errorf("filter expression: column %d: unknown operator %q", col, op)
+			}
+			tokens = append(tokens, exprToken{kind: tokOp, text: op, col: col})
+			i += len([]rune(op))
+		default:
+			return nil, fmt.Errorf("filter expression: column %d: unexpected character %q", col, r)
+		}
+	}
+	return append(tokens, exprToken{kind: tokEOF, col: len(runes) + 1}), nil
+}
+
+// exprParser is a recursive descent parser. Precedence, loosest first: or,
+// and, not, comparisons and parentheses.
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// keyword reports whether the next token is the given keyword, consuming it
+func (p *exprParser) keyword(word string) bool {
+	tok := p.peek()
+	if tok.kind == tokIdent && strings.EqualFold(tok.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if p.keyword("not") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, closing.errorf("expected \")\", got %s", closing)
+		}
+		return node, nil
+	case tokIdent:
+		return p.parseComparison(tok)
+	default:
+		return nil, tok.errorf("expected a field name or \"(\", got %s", tok)
+	}
+}
+
+func (p *exprParser) parseComparison(fieldTok exprToken) (exprNode, error) {
+	name := strings.ToLower(fieldTok.text)
+	field, ok := exprFields[name]
+	if !ok {
+		return nil, fieldTok.errorf("unknown field %q (want one of %s)", fieldTok.text, exprFieldNames)
+	}
+
+	opTok := p.next()
+	var op string
+	switch {
+	case opTok.kind == tokOp:
+		op = opTok.text
+	case opTok.kind == tokIdent && strings.EqualFold(opTok.text, "in"):
+		op = "in"
+	default:
+		return nil, opTok.errorf("expected an operator after %s, got %s", name, opTok)
+	}
+	if field.numeric && (op == "~" || op == "!~") {
+		return nil, opTok.errorf("%s is numeric and does not support %s", name, op)
+	}
+	if !field.numeric && strings.ContainsAny(op, "<>") {
+		return nil, opTok.errorf("%s is text and does not support %s", name, op)
+	}
+
+	var valueToks []exprToken
+	if op == "in" {
+		if open := p.next(); open.kind != tokLParen {
+			return nil, open.errorf("expected \"(\" after in, got %s", open)
+		}
+		if closing := p.peek(); closing.kind == tokRParen {
+			return nil, closing.errorf("empty list after in")
+		}
+		for {
+			value := p.next()
+			if value.kind != tokString && value.kind != tokNumber {
+				return nil, value.errorf("expected a value in list, got %s", value)
+			}
+			valueToks = append(valueToks, value)
+			sep := p.next()
+			if sep.kind == tokRParen {
+				break
+			}
+			if sep.kind != tokComma {
+				return nil, sep.errorf("expected \",\" or \")\" in list, got %s", sep)
+			}
+		}
+	} else {
+		valueToks = append(valueToks, p.next())
+	}
+
+	if !field.numeric {
+		cmp := stringCmp{field: field, op: op}
+		for _, v := range valueToks {
+			if v.kind != tokString {
+				return nil, v.errorf("expected a quoted string for %s, got %s", name, v)
+			}
+			cmp.values = append(cmp.values, strings.ToLower(v.text))
+		}
+		return cmp, nil
+	}
+
+	cmp := numberCmp{field: field, op: op}
+	for _, v := range valueToks {
+		n, err := numericValue(name, v)
+		if err != nil {
+			return nil, err
+		}
+		cmp.values = append(cmp.values, n)
+	}
+	return cmp, nil
+}
+
+// numericValue converts a number token, or an RFC3339 string for time
+func numericValue(field string, tok exprToken) (int64, error) {
+	switch {
+	case tok.kind == tokNumber:
+		n, err := strconv.ParseInt(tok.text, 10, 64)
+		if err != nil {
+			return 0, tok.errorf("invalid number %s", tok)
+		}
+		return n, nil
+	case tok.kind == tokString && field == "time":
+		t, err := time.Parse(time.RFC3339, tok.text)
+		if err != nil {
+			return 0, tok.errorf("invalid time %s (want RFC3339, e.g. \"2025-01-13T00:00:00Z\")", tok)
+		}
+		return t.Unix(), nil
+	default:
+		return 0, tok.errorf("expected a number for %s, got %s", field, tok)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+var exprStory = &Story{
+	ID:          42,
+	Title:       "Show HN: A Rust database",
+	URL:         "https://www.github.com/example/db",
+	By:          "alice",
+	Score:       120,
+	Time:        time.Date(2025, 1, 13, 12, 0, 0, 0, time.UTC).Unix(),
+	Type:        "story",
+	Descendants: 30,
+	Site:        "news.ycombinator.com",
+	Feed:        "top",
+	Feeds:       []string{"new", "top"},
+}
+
+func TestParseExprMatches(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		// Comparisons
+		{`type = "story"`, true},
+		{`type = "STORY"`, true},
+		{`type != "story"`, false},
+		{`title ~ "rust"`, true},
+		{`title !~ "rust"`, false},
+		{`domain = "github.com"`, true},
+		{`by = "Alice"`, true},
+		{`site = "news.ycombinator.com" and feed = "top"`, true},
+		{`score >= 120`, true},
+		{`score > 120`, false},
+		{`score < 121 and score <= 120`, true},
+		{`comments = 30 and id != 41`, true},
+		{`score = -1`, false},
+		{`time >= "2025-01-13T00:00:00Z"`, true},
+		{`time < "2025-01-13T00:00:00Z"`, false},
+
+		// feed matches any feed the story has appeared in
+		{`feed = "new"`, true},
+		{`feed = "best"`, false},
+		{`feed != "new"`, false},
+		{`feed != "best"`, true},
+		{`feed ~ "ne"`, true},
+		{`feed !~ "ne"`, false},
+		{`feed in ("best", "top")`, true},
+
+		// in lists
+		{`type in ("ask", "story")`, true},
+		{`type in ("ask")`, false},
+		{`score in (1, 120, 3)`, true},
+		{`score in (1, 2)`, false},
+
+		// not
+		{`not type = "ask"`, true},
+		{`not not type = "ask"`, false},
+		{`not (score > 100 and by = "alice")`, false},
+
+		// Precedence: not, then and, then or
+		{`type = "ask" and score > 100 or by = "alice"`, true},  // (false and true) or true
+		{`by = "alice" or type = "ask" and score > 1000`, true}, // true or (false and false)
+		{`(by = "alice" or type = "ask") and score > 1000`, false},
+		{`not type = "ask" and score > 1000`, false}, // (not false) and false
+		{`not (type = "ask" and score > 1000)`, true},
+
+		// Keywords are case-insensitive
+		{`TYPE IN ("story") AND NOT Score < 10`, true},
+	}
+	for _, tt := range tests {
+		expr, err := ParseExpr(tt.expr)
+		if err != nil {
+			t.Errorf("ParseExpr(%s): %v", tt.expr, err)
+			continue
+		}
+		if got := expr.Matches(exprStory); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		// Type errors
+		{`score ~ "1"`, `filter expression: column 7: score is numeric and does not support ~`},
+		{`title > "a"`, `filter expression: column 7: title is text and does not support >`},
+		{`title = 5`, `filter expression: column 9: expected a quoted string for title, got "5"`},
+		{`score = "high"`, `filter expression: column 9: expected a number for score, got "high"`},
+		{`time > "yesterday"`, `filter expression: column 8: invalid time "yesterday" (want RFC3339, e.g. "2025-01-13T00:00:00Z")`},
+
+		// Syntax errors
+		{`colour = "red"`, `filter expression: column 1: unknown field "colour" (want one of ` + exprFieldNames + `)`},
+		{`type "story"`, `filter expression: column 6: expected an operator after type, got "story"`},
+		{`type = "story" and`, `filter expression: column 19: expected a field name or "(", got end of expression`},
+		{`(type = "story"`, `filter expression: column 16: expected ")", got end of expression`},
+		{`type = "story")`, `filter expression: column 15: unexpected ")"`},
+		{`type = "story`, `filter expression: column 8: unterminated string`},
+		{`score => 5`, `filter expression: column 8: expected a number for score, got ">"`},
+		{`score !> 5`, `filter expression: column 7: unknown operator "!"`},
+		{`score = 5 & id = 1`, `filter expression: column 11: unexpected character '&'`},
+
+		// in lists
+		{`type in ()`, `filter expression: column 10: empty list after in`},
+		{`type in "story"`, `filter expression: column 9: expected "(" after in, got "story"`},
+		{`type in ("story" "ask")`, `filter expression: column 18: expected "," or ")" in list, got "ask"`},
+		{`type in ("story",)`, `filter expression: column 18: expected a value in list, got ")"`},
+		{`type in ("story", 5)`, `filter expression: column 19: expected a quoted string for type, got "5"`},
+		{`type in ("story"`, `filter expression: column 17: expected "," or ")" in list, got end of expression`},
+	}
+	for _, tt := range tests {
+		_, err := ParseExpr(tt.expr)
+		if err == nil {
+			t.Errorf("ParseExpr(%s) succeeded, want %q", tt.expr, tt.want)
+			continue
+		}
+		if err.Error() != tt.want {
+			t.Errorf("ParseExpr(%s) error\n got: %s\nwant: %s", tt.expr, err, tt.want)
+		}
+	}
+}
@@ -2,8 +2,6 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -11,40 +9,75 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
-	"sort"
+	"regexp"
 	"strconv"
 	"strings"
-	"sync"
 	"syscall"
 	"time"
 
+	"github.com/JohnCrickett/top-stories/keywords"
 	"github.com/segmentio/kafka-go"
 	"gopkg.in/yaml.v3"
 )
 
+// commitInterval is how often consumed offsets are committed to the group
+const commitInterval = time.Second
+
+// Backoff between attempts to apply a message the store failed to write
+const (
+	storeRetryMin = time.Second
+	storeRetryMax = time.Minute
+)
+
 type Config struct {
 	Kafka  KafkaConfig  `yaml:"kafka"`
 	API    APIConfig    `yaml:"api"`
 	Filter FilterConfig `yaml:"filter"`
+	// Feeds are additional named feeds, each with its own filter, served
+	// from the same store as the default feed built from Filter
+	Feeds []FeedConfig `yaml:"feeds"`
+	Store StoreConfig  `yaml:"store"`
+	// Retention bounds how many stories are kept, and for how long
+	Retention RetentionConfig `yaml:"retention"`
 }
 
 type KafkaConfig struct {
-	Broker         string `yaml:"broker"`
-	Topic          string `yaml:"topic"`
+	Broker string `yaml:"broker"`
+	Topic  string `yaml:"topic"`
+	// ConsumerGroup tracks offsets so a restarted instance resumes where it
+	// left off; empty re-reads every partition from the start on every run.
+	// Only used with a persistent store.
 	ConsumerGroup  string `yaml:"consumer_group"`
 	CACertPath     string `yaml:"ca_cert_path"`
 	ClientCertPath string `yaml:"client_cert_path"`
 	ClientKeyPath  string `yaml:"client_key_path"`
+	// SecurityProtocol is plaintext, ssl (default), sasl_plaintext or sasl_ssl
+	SecurityProtocol string     `yaml:"security_protocol"`
+	SASL             SASLConfig `yaml:"sasl"`
 }
 
 type APIConfig struct {
 	Port int `yaml:"port"`
+	// AdminToken enables the /admin endpoints for requests that send it as a
+	// bearer token; empty disables them
+	AdminToken string `yaml:"admin_token"`
 }
 
+// FilterConfig is also the body of the /admin/filter endpoints
 type FilterConfig struct {
-	StoryTypes   []string `yaml:"story_types"`
-	Keywords     []string `yaml:"keywords"`
-	MinimumScore int      `yaml:"minimum_score"`
+	StoryTypes   []string `yaml:"story_types" json:"story_types"`
+	Keywords     []string `yaml:"keywords" json:"keywords"`
+	MinimumScore int      `yaml:"minimum_score" json:"minimum_score"`
+	SourceFeeds  []string `yaml:"source_feeds" json:"source_feeds"`
+	// Authors and Domains keep only stories by one of the authors or linking
+	// to one of the domains (or their subdomains); the Exclude lists drop them
+	Authors        []string `yaml:"authors" json:"authors"`
+	ExcludeAuthors []string `yaml:"exclude_authors" json:"exclude_authors"`
+	Domains        []string `yaml:"domains" json:"domains"`
+	ExcludeDomains []string `yaml:"exclude_domains" json:"exclude_domains"`
+	// Expression is a boolean filter expression (see filterexpr.go), ANDed
+	// with the options above
+	Expression string `yaml:"expression" json:"expression"`
 }
 
 type Story struct {
@@ -55,80 +88,165 @@ type Story struct {
 	Score int    `json:"score"`
 	Time  int64  `json:"time"`
 	Type  string `json:"type"`
+	// Descendants is the total comment count
+	Descendants int `json:"descendants"`
+	// Site is the host the story was collected from, e.g. news.ycombinator.com
+	Site string `json:"site,omitempty"`
+	// Feed is the scraper feed or source the story was published from
+	Feed string `json:"feed,omitempty"`
+	// Feeds are all the feeds the story has appeared in, including Feed
+	Feeds []string `json:"feeds,omitempty"`
 }
 
-type StoryStore struct {
-	mu      sync.RWMutex
-	stories map[int]*Story // ID -> Story
+// feeds returns the feeds a story has appeared in. Stories stored before
+// feed sets were published only have Feed.
+func (s *Story) feeds() []string {
+	if len(s.Feeds) == 0 && s.Feed != "" {
+		return []string{s.Feed}
+	}
+	return s.Feeds
 }
 
 type Server struct {
-	store    *StoryStore
-	config   Config
-	reader   *kafka.Reader
-	ctx      context.Context
-	cancel   context.CancelFunc
-	filter   *StoryFilter
+	store  StoryStore
+	index  *SearchIndex
+	config Config
+	reader messageReader
+	ctx    context.Context
+	cancel context.CancelFunc
+	// feeds are the feeds this instance serves; the first is the default
+	feeds []*Feed
+	stats *FilterStats
 }
 
+// Filter clauses, named after their config keys, as reported by
+// StoryFilter.Reject
+const (
+	clauseStoryTypes     = "story_types"
+	clauseSourceFeeds    = "source_feeds"
+	clauseMinimumScore   = "minimum_score"
+	clauseKeywords       = "keywords"
+	clauseAuthors        = "authors"
+	clauseExcludeAuthors = "exclude_authors"
+	clauseDomains        = "domains"
+	clauseExcludeDomains = "exclude_domains"
+	clauseExpression     = "expression"
+)
+
 type StoryFilter struct {
+	config       FilterConfig
 	storyTypes   map[string]bool // For O(1) lookups
-	keywords     []string
+	sourceFeeds  map[string]bool
+	keywords     []*regexp.Regexp // compiled keyword entries, see keywords.go
 	minimumScore int
-	enabled      bool // true if any filter is configured
+	matcher      storyMatcher // authors and domains
+	expr         *Expr        // nil if no expression is configured
+	enabled      bool         // true if any filter is configured
 }
 
-// NewStoryFilter creates a filter from config
-func NewStoryFilter(cfg FilterConfig) *StoryFilter {
+// NewStoryFilter creates a filter from config, failing if a keyword or the
+// expression doesn't compile
+func NewStoryFilter(cfg FilterConfig) (*StoryFilter, error) {
 	filter := &StoryFilter{
+		config:       cfg,
 		storyTypes:   make(map[string]bool),
-		keywords:     cfg.Keywords,
+		sourceFeeds:  make(map[string]bool),
 		minimumScore: cfg.MinimumScore,
+		matcher:      newStoryMatcher(cfg.Authors, cfg.ExcludeAuthors, cfg.Domains, cfg.ExcludeDomains),
 	}
 
 	// Populate storyTypes map for O(1) lookups
 	for _, t := range cfg.StoryTypes {
 		filter.storyTypes[t] = true
 	}
+	for _, f := range cfg.SourceFeeds {
+		filter.sourceFeeds[f] = true
+	}
+
+	compiled, err := keywords.Compile(cfg.Keywords)
+	if err != nil {
+		return nil, err
+	}
+	filter.keywords = compiled
+
+	if strings.TrimSpace(cfg.Expression) != "" {
+		expr, err := ParseExpr(cfg.Expression)
+		if err != nil {
+			return nil, err
+		}
+		filter.expr = expr
+	}
 
 	// Filter is enabled if any filter constraint is specified
-	filter.enabled = len(cfg.StoryTypes) > 0 || len(cfg.Keywords) > 0 || cfg.MinimumScore > 0
+	filter.enabled = len(cfg.StoryTypes) > 0 || len(cfg.Keywords) > 0 || cfg.MinimumScore > 0 ||
+		len(cfg.SourceFeeds) > 0 || filter.matcher.enabled() || filter.expr != nil
+
+	return filter, nil
+}
 
-	return filter
+// inSourceFeeds reports whether a story has appeared in any of the source
+// feeds
+func (f *StoryFilter) inSourceFeeds(story *Story) bool {
+	for _, feed := range story.feeds() {
+		if f.sourceFeeds[feed] {
+			return true
+		}
+	}
+	return false
 }
 
 // Matches returns true if a story passes all configured filters
 func (f *StoryFilter) Matches(story *Story) bool {
+	return f.Reject(story) == ""
+}
+
+// Reject returns the first configured clause a story fails, or "" if it
+// passes all of them
+func (f *StoryFilter) Reject(story *Story) string {
 	if !f.enabled {
-		return true // No filters configured, match everything
+		return "" // No filters configured, match everything
 	}
 
 	// Check story type filter
 	if len(f.storyTypes) > 0 && !f.storyTypes[story.Type] {
-		return false
+		return clauseStoryTypes
+	}
+
+	// Check source feed filter
+	if len(f.sourceFeeds) > 0 && !f.inSourceFeeds(story) {
+		return clauseSourceFeeds
 	}
 
 	// Check minimum score filter
 	if story.Score < f.minimumScore {
-		return false
+		return clauseMinimumScore
 	}
 
 	// Check keywords filter (match if ANY keyword is found in title)
 	if len(f.keywords) > 0 {
-		titleLower := strings.ToLower(story.Title)
 		matched := false
 		for _, keyword := range f.keywords {
-			if strings.Contains(titleLower, strings.ToLower(keyword)) {
+			if keyword.MatchString(story.Title) {
 				matched = true
 				break
 			}
 		}
 		if !matched {
-			return false
+			return clauseKeywords
 		}
 	}
 
-	return true
+	// Check author and domain lists
+	if clause := f.matcher.reject(story); clause != "" {
+		return clause
+	}
+
+	// Check filter expression
+	if f.expr != nil && !f.expr.Matches(story) {
+		return clauseExpression
+	}
+
+	return ""
 }
 
 func loadConfig(path string) (*Config, error) {
@@ -163,62 +281,118 @@ func loadConfig(path string) (*Config, error) {
 	return &cfg, nil
 }
 
-func createKafkaReader(cfg KafkaConfig) (*kafka.Reader, error) {
-	keypair, err := tls.LoadX509KeyPair(cfg.ClientCertPath, cfg.ClientKeyPath)
+func createKafkaReader(cfg KafkaConfig) (messageReader, error) {
+	dialer, err := createKafkaDialer(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load client cert/key: %w", err)
-	}
-
-	caCert, err := os.ReadFile(cfg.CACertPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read CA cert: %w", err)
-	}
-
-	caCertPool := x509.NewCertPool()
-	if !caCertPool.AppendCertsFromPEM(caCert) {
-		return nil, fmt.Errorf("failed to parse CA cert")
+		return nil, err
 	}
 
-	dialer := &kafka.Dialer{
-		Timeout:   10 * time.Second,
-		DualStack: true,
-		TLS: &tls.Config{
-			Certificates: []tls.Certificate{keypair},
-			RootCAs:      caCertPool,
-		},
+	// A reader without a group reads a single partition, so read each one
+	if cfg.ConsumerGroup == "" {
+		fmt.Printf("[DEBUG] Kafka reader configured for broker: %s, topic: %s (no consumer group, reading every partition from the start)\n",
+			cfg.Broker, cfg.Topic)
+		return newPartitionsReader(cfg, dialer), nil
 	}
 
 	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:     []string{cfg.Broker},
-		Topic:       cfg.Topic,
-		StartOffset: kafka.FirstOffset,
-		Dialer:      dialer,
-		// Note: Not using consumer groups so no offsets are tracked across restarts
-		// Each instance always reads from the beginning
+		Brokers: []string{cfg.Broker},
+		Topic:   cfg.Topic,
+		// With a group, a new group starts at the beginning of the topic and
+		// later runs resume from the committed offsets. Commits are flushed
+		// in the background and on Close.
+		GroupID:        cfg.ConsumerGroup,
+		StartOffset:    kafka.FirstOffset,
+		CommitInterval: commitInterval,
+		Dialer:         dialer,
 	})
 
-	fmt.Printf("[DEBUG] Kafka reader configured for broker: %s, topic: %s\n",
-		cfg.Broker, cfg.Topic)
+	fmt.Printf("[DEBUG] Kafka reader configured for broker: %s, topic: %s, consumer group: %s\n",
+		cfg.Broker, cfg.Topic, cfg.ConsumerGroup)
 	return reader, nil
 }
 
 func NewServer(cfg Config) (*Server, error) {
+	// Resuming from committed offsets would leave a memory store empty after
+	// a restart, so without a persistent store the topic is always re-read
+	if cfg.Kafka.ConsumerGroup != "" && !cfg.Store.persistent() {
+		fmt.Printf("[CONFIG] Not joining consumer group %s: the memory store starts empty, so the topic is re-read on every start (use the bolt store to resume from committed offsets)\n",
+			cfg.Kafka.ConsumerGroup)
+		cfg.Kafka.ConsumerGroup = ""
+	}
+
 	reader, err := createKafkaReader(cfg.Kafka)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Kafka reader: %w", err)
 	}
 
-	filter := NewStoryFilter(cfg.Filter)
+	feeds, err := newFeeds(cfg)
+	if err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("invalid filter config: %w", err)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	return &Server{
-		store:  &StoryStore{stories: make(map[int]*Story)},
+	server := &Server{
 		config: cfg,
 		reader: reader,
 		ctx:    ctx,
 		cancel: cancel,
-		filter: filter,
-	}, nil
+		feeds:  feeds,
+		stats:  NewFilterStats(),
+	}
+
+	store, err := newStoryStore(cfg.Store)
+	if err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("failed to open story store: %w", err)
+	}
+	if err := pruneStore(store, server.wanted); err != nil {
+		store.Close()
+		reader.Close()
+		return nil, err
+	}
+	server.index = NewSearchIndex()
+	server.store, err = newIndexedStore(store, server.index)
+	if err != nil {
+		store.Close()
+		reader.Close()
+		return nil, err
+	}
+	return server, nil
+}
+
+// pruneStore drops stored stories that no feed wants any more, e.g. after
+// the filter config has changed since a persistent store was written
+func pruneStore(store StoryStore, wanted func(*Story) bool) error {
+	kept, removed, err := applyFilter(store, wanted)
+	if err != nil {
+		return err
+	}
+	if kept+removed > 0 {
+		fmt.Printf("[STORE] Loaded %d stories, removed %d that no longer match filters\n", kept, removed)
+	}
+	return nil
+}
+
+// applyFilter removes the stored stories that wanted rejects, returning how
+// many were kept and removed
+func applyFilter(store StoryStore, wanted func(*Story) bool) (int, int, error) {
+	stories, err := store.GetAllStories()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to load stored stories: %w", err)
+	}
+
+	removed := 0
+	for _, story := range stories {
+		if wanted(story) {
+			continue
+		}
+		if _, err := store.RemoveStory(story.ID); err != nil {
+			return len(stories) - removed, removed, err
+		}
+		removed++
+	}
+	return len(stories) - removed, removed, nil
 }
 
 // consumeMessages reads messages from Kafka and adds them to the store
@@ -241,55 +415,126 @@ func (s *Server) consumeMessages() {
 			continue
 		}
 
-		var story Story
-		if err := json.Unmarshal(msg.Value, &story); err != nil {
-			fmt.Printf("[ERROR] Failed to unmarshal story: %v\n", err)
-			continue
+		s.stats.consumedMessage()
+		if !s.processMessage(msg) {
+			return
 		}
 
-		// Apply filter before storing
-		if !s.filter.Matches(&story) {
-			fmt.Printf("[FILTERED] Story ID %d: %s (Type: %s, Score: %d)\n",
-				story.ID, story.Title, story.Type, story.Score)
-			continue
+		// The message has been stored, filtered out or skipped, so the
+		// group can move past it
+		if s.config.Kafka.ConsumerGroup != "" {
+			if err := s.reader.CommitMessages(s.ctx, msg); err != nil && s.ctx.Err() == nil {
+				fmt.Printf("[ERROR] Failed to commit offset %d: %v\n", msg.Offset, err)
+			}
 		}
-
-		s.store.AddStory(&story)
-		fmt.Printf("[STORED] Story ID %d: %s (Score: %d)\n", story.ID, story.Title, story.Score)
 	}
 }
 
-func (s *StoryStore) AddStory(story *Story) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.stories[story.ID] = story
+// processMessage applies a message, retrying store failures with backoff
+// until they succeed so that its offset is only committed once the story has
+// been stored or filtered out. It returns false if the server shut down first,
+// leaving the message uncommitted.
+func (s *Server) processMessage(msg kafka.Message) bool {
+	backoff := storeRetryMin
+	for {
+		err := s.handleMessage(msg)
+		if err == nil {
+			return true
+		}
+		s.stats.failedMessage()
+		fmt.Printf("[ERROR] Failed to apply message at offset %d, retrying in %v: %v\n", msg.Offset, backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-s.ctx.Done():
+			return false
+		}
+		backoff = min(2*backoff, storeRetryMax)
+	}
 }
 
-func (s *StoryStore) GetAllStories() []*Story {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// handleMessage applies one message to the store. It only returns store
+// errors, which are worth retrying; undecodable messages are logged and
+// skipped.
+func (s *Server) handleMessage(msg kafka.Message) error {
+	event, err := decodeMessage(msg)
+	if err != nil {
+		s.stats.failedMessage()
+		fmt.Printf("[ERROR] Failed to decode message at offset %d: %v\n", msg.Offset, err)
+		return nil
+	}
+	story := event.Story
+
+	if event.Event == eventDeleted {
+		removed, err := s.store.RemoveStory(story.ID)
+		if err != nil {
+			return err
+		}
+		if removed {
+			s.stats.deletedStory()
+			fmt.Printf("[DELETED] Story ID %d\n", story.ID)
+		}
+		return nil
+	}
+
+	// Apply filters before storing: a story is kept if any feed wants it. An
+	// update can make a stored story stop matching (e.g. a retitle), in which
+	// case the old copy is dropped.
+	if rejectedBy := s.rejections(story); rejectedBy != nil {
+		removed, err := s.store.RemoveStory(story.ID)
+		if err != nil {
+			return err
+		}
+		s.stats.rejectedStory(story, rejectedBy, removed)
+		if removed {
+			fmt.Printf("[REMOVED] Story ID %d no longer matches filters: %s\n", story.ID, story.Title)
+			return nil
+		}
+		fmt.Printf("[FILTERED] Story ID %d: %s (Type: %s, Score: %d)\n",
+			story.ID, story.Title, story.Type, story.Score)
+		return nil
+	}
 
-	stories := make([]*Story, 0, len(s.stories))
-	for _, story := range s.stories {
-		stories = append(stories, story)
+	replaced, err := s.store.AddStory(story)
+	if err != nil {
+		return err
+	}
+	s.stats.storedStory(replaced)
+	if replaced {
+		fmt.Printf("[UPDATED] Story ID %d: %s (Score: %d)\n", story.ID, story.Title, story.Score)
+	} else {
+		fmt.Printf("[STORED] Story ID %d: %s (Score: %d)\n", story.ID, story.Title, story.Score)
 	}
-	return stories
+	return nil
 }
 
-// handleGetStories handles GET /stories with optional filtering and sorting
+// handleGetStories handles GET /stories and GET /feeds/{name}/stories with
+// optional filtering and sorting
 func (s *Server) handleGetStories(w http.ResponseWriter, r *http.Request) {
 	// Add CORS headers
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-	
+
 	// Handle preflight requests
 	if r.Method == http.MethodOptions {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
-	
-	stories := s.store.GetAllStories()
+
+	feed := s.feedFromPath(r)
+	if feed == nil {
+		http.NotFound(w, r)
+		return
+	}
+	feedFilter := feed.filter.Load()
+
+	stories, err := s.store.GetAllStories()
+	if err != nil {
+		fmt.Printf("[ERROR] %v\n", err)
+		http.Error(w, "failed to load stories", http.StatusInternalServerError)
+		return
+	}
 
 	// Parse query parameters
 	minScore := 0
@@ -316,13 +561,35 @@ func (s *Server) handleGetStories(w http.ResponseWriter, r *http.Request) {
 			untilTime = t.Unix()
 		}
 	}
+	matcher := newStoryMatcher(listParam(r, "author"), listParam(r, "exclude_author"),
+		listParam(r, "domain"), listParam(r, "exclude_domain"))
+
+	// Unlike the other parameters, a bad expression is an error rather than
+	// being ignored
+	var expr *Expr
+	if q := r.URL.Query().Get("q"); strings.TrimSpace(q) != "" {
+		if expr, err = ParseExpr(q); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
 	// Filter stories
 	filtered := make([]*Story, 0, len(stories))
 	for _, story := range stories {
+		// The store is shared by all feeds
+		if !feedFilter.Matches(story) {
+			continue
+		}
 		if story.Score < minScore || story.Score > maxScore {
 			continue
 		}
+		if !matcher.matches(story) {
+			continue
+		}
+		if expr != nil && !expr.Matches(story) {
+			continue
+		}
 		if sinceTime > 0 && story.Time < sinceTime {
 			continue
 		}
@@ -333,30 +600,111 @@ func (s *Server) handleGetStories(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Sort stories
-	sortBy := r.URL.Query().Get("sort")
-	switch sortBy {
-	case "oldest":
-		sort.Slice(filtered, func(i, j int) bool {
-			return filtered[i].Time < filtered[j].Time
-		})
-	case "popularity":
-		sort.Slice(filtered, func(i, j int) bool {
-			return filtered[i].Score > filtered[j].Score
-		})
-	case "latest":
-		fallthrough
-	default:
-		sort.Slice(filtered, func(i, j int) bool {
-			return filtered[i].Time > filtered[j].Time
-		})
+	sortBy := normalizeSort(r.URL.Query().Get("sort"))
+	sortStories(filtered, sortBy)
+
+	// Paginate if asked to; plain requests keep getting the whole array
+	page, paginated, err := paginate(filtered, sortBy, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	if paginated {
+		json.NewEncoder(w).Encode(page)
+		return
+	}
 	json.NewEncoder(w).Encode(filtered)
 }
 
+// handleSearch handles GET /search?q= and GET /feeds/{name}/search?q= with
+// ranked results
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	feed := s.feedFromPath(r)
+	if feed == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	resp := SearchResponse{
+		Version: 1,
+		Query:   query.Get("q"),
+		Limit:   defaultSearchLimit,
+	}
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxSearchLimit), http.StatusBadRequest)
+			return
+		}
+		resp.Limit = limit
+	}
+	if o := query.Get("offset"); o != "" {
+		offset, err := strconv.Atoi(o)
+		if err != nil || offset < 0 {
+			http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		resp.Offset = offset
+	}
+
+	results, err := s.index.Search(resp.Query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	feedFilter := feed.filter.Load()
+	matcher := newStoryMatcher(listParam(r, "author"), listParam(r, "exclude_author"),
+		listParam(r, "domain"), listParam(r, "exclude_domain"))
+	matching := results[:0]
+	for _, result := range results {
+		if feedFilter.Matches(result.Story) && matcher.matches(result.Story) {
+			matching = append(matching, result)
+		}
+	}
+	results = matching
+	resp.Total = len(results)
+	start := min(resp.Offset, len(results))
+	resp.Results = results[start:min(start+resp.Limit, len(results))]
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// listParam collects a list query parameter given either repeatedly or
+// comma-separated, e.g. ?domain=a.com&domain=b.org or ?domain=a.com,b.org
+func listParam(r *http.Request, name string) []string {
+	var values []string
+	for _, v := range r.URL.Query()[name] {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
 func (s *Server) setupRoutes() {
 	http.HandleFunc("/stories", s.handleGetStories)
+	http.HandleFunc("/search", s.handleSearch)
+	http.HandleFunc("/feeds", s.handleListFeeds)
+	http.HandleFunc("/stats/filter", s.handleFilterStats)
+	http.HandleFunc("/feeds/{name}/stories", s.handleGetStories)
+	http.HandleFunc("/feeds/{name}/search", s.handleSearch)
+	http.HandleFunc("/admin/filter", s.requireAdmin(s.handleAdminFilter))
+	http.HandleFunc("/admin/feeds/{name}/filter", s.requireAdmin(s.handleAdminFilter))
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		// Add CORS headers
 		w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -365,30 +713,73 @@ func (s *Server) setupRoutes() {
 	})
 }
 
+// logFilter prints a feed filter's configuration
+func logFilter(feed string, filter *StoryFilter) {
+	if filter.enabled {
+		fmt.Printf("[CONFIG] Consumer-side filtering enabled for feed %s:\n", feed)
+		if len(filter.storyTypes) > 0 {
+			fmt.Printf("  Story types: %v\n", filter.config.StoryTypes)
+		}
+		if len(filter.keywords) > 0 {
+			fmt.Printf("  Keywords: %v\n", filter.config.Keywords)
+		}
+		if filter.minimumScore > 0 {
+			fmt.Printf("  Minimum score: %d\n", filter.minimumScore)
+		}
+		if len(filter.sourceFeeds) > 0 {
+			fmt.Printf("  Source feeds: %v\n", filter.config.SourceFeeds)
+		}
+		if len(filter.config.Authors) > 0 {
+			fmt.Printf("  Authors: %v\n", filter.config.Authors)
+		}
+		if len(filter.config.ExcludeAuthors) > 0 {
+			fmt.Printf("  Excluded authors: %v\n", filter.config.ExcludeAuthors)
+		}
+		if len(filter.matcher.domains) > 0 {
+			fmt.Printf("  Domains: %v\n", filter.matcher.domains)
+		}
+		if len(filter.matcher.excludeDomains) > 0 {
+			fmt.Printf("  Excluded domains: %v\n", filter.matcher.excludeDomains)
+		}
+		if filter.expr != nil {
+			fmt.Printf("  Expression: %s\n", filter.expr)
+		}
+		fmt.Println()
+	} else {
+		fmt.Printf("[CONFIG] No filters configured for feed %s - consuming all stories\n", feed)
+		fmt.Println()
+	}
+}
+
 func (s *Server) start() {
 	s.setupRoutes()
 
-	// Log filter configuration
-	if s.filter.enabled {
-		fmt.Println("\n[CONFIG] Consumer-side filtering enabled:")
-		if len(s.filter.storyTypes) > 0 {
-			fmt.Printf("  Story types: %v\n", s.config.Filter.StoryTypes)
+	fmt.Println()
+	for _, feed := range s.feeds {
+		logFilter(feed.name, feed.filter.Load())
+	}
+
+	if r := s.config.Retention; r.enabled() {
+		fmt.Println("[CONFIG] Retention enabled:")
+		if r.MaxAgeHours > 0 {
+			fmt.Printf("  Maximum age: %dh\n", r.MaxAgeHours)
 		}
-		if len(s.filter.keywords) > 0 {
-			fmt.Printf("  Keywords: %v\n", s.config.Filter.Keywords)
+		if r.MaxStories > 0 {
+			fmt.Printf("  Maximum stories: %d\n", r.MaxStories)
 		}
-		if s.filter.minimumScore > 0 {
-			fmt.Printf("  Minimum score: %d\n", s.filter.minimumScore)
+		if r.MinScore > 0 {
+			fmt.Printf("  Minimum score: %d after %dh\n", r.MinScore, r.MinScoreAfterHours)
 		}
 		fmt.Println()
-	} else {
-		fmt.Println("\n[CONFIG] No filters configured - consuming all stories\n")
 	}
 
 	addr := fmt.Sprintf(":%d", s.config.API.Port)
 	fmt.Printf("Starting API server on %s\n", addr)
 
 	go s.consumeMessages()
+	if s.config.Retention.enabled() {
+		go s.runJanitor()
+	}
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -421,6 +812,10 @@ func (s *Server) start() {
 		fmt.Printf("[ERROR] Reader close error: %v\n", err)
 	}
 
+	if err := s.store.Close(); err != nil {
+		fmt.Printf("[ERROR] Store close error: %v\n", err)
+	}
+
 	fmt.Println("Server shutdown complete")
 }
 
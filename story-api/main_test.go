@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestStoryFilterSourceFeeds(t *testing.T) {
+	filter, err := NewStoryFilter(FilterConfig{SourceFeeds: []string{"top"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		story *Story
+		want  bool
+	}{
+		// Most top stories are published from new first
+		{&Story{Feed: "new", Feeds: []string{"new", "top"}}, true},
+		{&Story{Feed: "top", Feeds: []string{"top"}}, true},
+		{&Story{Feed: "new", Feeds: []string{"new"}}, false},
+		// Stories stored before feed sets only have Feed
+		{&Story{Feed: "top"}, true},
+		{&Story{Feed: "new"}, false},
+	}
+	for _, tt := range tests {
+		if got := filter.Matches(tt.story); got != tt.want {
+			t.Errorf("feed %q, feeds %v: Matches = %v, want %v", tt.story.Feed, tt.story.Feeds, got, tt.want)
+		}
+	}
+}
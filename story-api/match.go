@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// normalizeDomain brings a host or URL into the form domains are compared
+// in: lowercase Unicode without a port, trailing dot or leading "www.".
+// Punycode labels (xn--...) are decoded, so "xn--bcher-kva.de" and
+// "bücher.de" are the same domain.
+func normalizeDomain(value string) string {
+	host := strings.TrimSpace(value)
+	if strings.Contains(host, "://") {
+		u, err := url.Parse(host)
+		if err != nil {
+			return ""
+		}
+		host = u.Hostname()
+	} else if h, _, found := strings.Cut(host, "/"); found {
+		host = h
+	}
+	if h, port, found := strings.Cut(host, ":"); found && !strings.Contains(port, ":") {
+		host = h
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if unicodeHost, err := idna.Punycode.ToUnicode(host); err == nil {
+		host = unicodeHost
+	}
+	return strings.TrimPrefix(host, "www.")
+}
+
+// storyDomain returns the normalized host of a story's URL
+func storyDomain(rawURL string) string {
+	if rawURL == "" {
+		return ""
+	}
+	return normalizeDomain(rawURL)
+}
+
+// domainList matches a host against domains, including their subdomains:
+// "github.com" matches "github.com" and "gist.github.com" but not
+// "notgithub.com"
+type domainList []string
+
+func newDomainList(domains []string) domainList {
+	var list domainList
+	for _, d := range domains {
+		if d = normalizeDomain(d); d != "" {
+			list = append(list, d)
+		}
+	}
+	return list
+}
+
+func (l domainList) matches(host string) bool {
+	if host == "" {
+		return false
+	}
+	for _, d := range l {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorList matches HN usernames case-insensitively
+type authorList map[string]bool
+
+func newAuthorList(authors []string) authorList {
+	list := make(authorList)
+	for _, a := range authors {
+		if a = strings.ToLower(strings.TrimSpace(a)); a != "" {
+			list[a] = true
+		}
+	}
+	return list
+}
+
+func (l authorList) matches(author string) bool {
+	return l[strings.ToLower(author)]
+}
+
+// storyMatcher applies include and exclude lists for authors and domains.
+// Include lists pass stories matching any entry; exclude lists reject stories
+// matching any entry. Empty lists don't constrain.
+type storyMatcher struct {
+	authors        authorList
+	excludeAuthors authorList
+	domains        domainList
+	excludeDomains domainList
+}
+
+func newStoryMatcher(authors, excludeAuthors, domains, excludeDomains []string) storyMatcher {
+	return storyMatcher{
+		authors:        newAuthorList(authors),
+		excludeAuthors: newAuthorList(excludeAuthors),
+		domains:        newDomainList(domains),
+		excludeDomains: newDomainList(excludeDomains),
+	}
+}
+
+func (m storyMatcher) enabled() bool {
+	return len(m.authors) > 0 || len(m.excludeAuthors) > 0 || len(m.domains) > 0 || len(m.excludeDomains) > 0
+}
+
+func (m storyMatcher) matches(story *Story) bool {
+	return m.reject(story) == ""
+}
+
+// reject returns the clause that rejects a story, or "" if it matches
+func (m storyMatcher) reject(story *Story) string {
+	if len(m.authors) > 0 && !m.authors.matches(story.By) {
+		return clauseAuthors
+	}
+	if m.excludeAuthors.matches(story.By) {
+		return clauseExcludeAuthors
+	}
+	if len(m.domains) == 0 && len(m.excludeDomains) == 0 {
+		return ""
+	}
+
+	host := storyDomain(story.URL)
+	if len(m.domains) > 0 && !m.domains.matches(host) {
+		return clauseDomains
+	}
+	if m.excludeDomains.matches(host) {
+		return clauseExcludeDomains
+	}
+	return ""
+}
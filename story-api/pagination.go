@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+const (
+	// storiesResponseVersion is the version of the paginated response
+	// envelope. Requests without pagination parameters still get a bare array.
+	storiesResponseVersion = 1
+
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// Sort orders accepted by GET /stories
+const (
+	sortLatest     = "latest"
+	sortOldest     = "oldest"
+	sortPopularity = "popularity"
+)
+
+// StoriesPage is the paginated GET /stories response
+type StoriesPage struct {
+	Version int `json:"version"`
+	// Total is the number of stories matching the query, across all pages
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset,omitempty"`
+	// NextCursor fetches the page after this one; empty on the last page
+	NextCursor string   `json:"next_cursor,omitempty"`
+	Stories    []*Story `json:"stories"`
+}
+
+// pageCursor is the position after the last story of a page. It records the
+// sort key and ID rather than an index, so pages stay consistent while stories
+// are added or removed.
+type pageCursor struct {
+	Sort string `json:"s"`
+	Key  int64  `json:"k"`
+	ID   int    `json:"i"`
+}
+
+// normalizeSort maps the sort parameter to one of the supported orders
+func normalizeSort(sortBy string) string {
+	switch sortBy {
+	case sortOldest, sortPopularity:
+		return sortBy
+	default:
+		return sortLatest
+	}
+}
+
+// storyLess orders stories for a sort, breaking ties by ID so that the order
+// is total and cursors are unambiguous
+func storyLess(sortBy string) func(a, b *Story) bool {
+	return func(a, b *Story) bool {
+		ka, kb := sortKey(sortBy, a), sortKey(sortBy, b)
+		if ka != kb {
+			if sortBy == sortOldest {
+				return ka < kb
+			}
+			return ka > kb
+		}
+		if sortBy == sortOldest {
+			return a.ID < b.ID
+		}
+		return a.ID > b.ID
+	}
+}
+
+func sortKey(sortBy string, story *Story) int64 {
+	if sortBy == sortPopularity {
+		return int64(story.Score)
+	}
+	return story.Time
+}
+
+// sortStories sorts stories in place in the given order
+func sortStories(stories []*Story, sortBy string) {
+	less := storyLess(sortBy)
+	sort.Slice(stories, func(i, j int) bool {
+		return less(stories[i], stories[j])
+	})
+}
+
+func encodeCursor(c pageCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (pageCursor, error) {
+	var c pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	return c, nil
+}
+
+// paginate applies the limit, offset and cursor parameters to stories, which
+// must already be sorted by sortBy. It reports false if the request has no
+// pagination parameters, in which case the caller responds with the legacy
+// bare array.
+func paginate(stories []*Story, sortBy string, query url.Values) (*StoriesPage, bool, error) {
+	limitParam, offsetParam, cursorParam := query.Get("limit"), query.Get("offset"), query.Get("cursor")
+	if limitParam == "" && offsetParam == "" && cursorParam == "" {
+		return nil, false, nil
+	}
+	if offsetParam != "" && cursorParam != "" {
+		return nil, true, fmt.Errorf("use either offset or cursor, not both")
+	}
+
+	page := &StoriesPage{
+		Version: storiesResponseVersion,
+		Total:   len(stories),
+		Limit:   defaultPageLimit,
+	}
+	if limitParam != "" {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return nil, true, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+		page.Limit = limit
+	}
+
+	start := 0
+	if offsetParam != "" {
+		offset, err := strconv.Atoi(offsetParam)
+		if err != nil || offset < 0 {
+			return nil, true, fmt.Errorf("offset must be a non-negative integer")
+		}
+		page.Offset = offset
+		start = min(offset, len(stories))
+	}
+	if cursorParam != "" {
+		cursor, err := decodeCursor(cursorParam)
+		if err != nil {
+			return nil, true, err
+		}
+		if cursor.Sort != sortBy {
+			return nil, true, fmt.Errorf("cursor was issued for sort=%s", cursor.Sort)
+		}
+
+		// First story that sorts after the cursor position
+		pivot := &Story{ID: cursor.ID, Time: cursor.Key, Score: int(cursor.Key)}
+		less := storyLess(sortBy)
+		start = sort.Search(len(stories), func(i int) bool {
+			return less(pivot, stories[i])
+		})
+	}
+
+	end := min(start+page.Limit, len(stories))
+	page.Stories = stories[start:end]
+	if end < len(stories) {
+		last := stories[end-1]
+		page.NextCursor = encodeCursor(pageCursor{Sort: sortBy, Key: sortKey(sortBy, last), ID: last.ID})
+	}
+	return page, true, nil
+}
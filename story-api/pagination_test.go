@@ -0,0 +1,162 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+// pageStories has ties on both Time and Score, so cursors have to break them
+// by ID
+func pageStories() []*Story {
+	return []*Story{
+		{ID: 1, Time: 100, Score: 5},
+		{ID: 2, Time: 300, Score: 50},
+		{ID: 3, Time: 200, Score: 5},
+		{ID: 4, Time: 200, Score: 20},
+		{ID: 5, Time: 400, Score: 50},
+		{ID: 6, Time: 200, Score: 1},
+		{ID: 7, Time: 100, Score: 50},
+	}
+}
+
+func storyIDs(stories []*Story) []int {
+	ids := make([]int, len(stories))
+	for i, s := range stories {
+		ids[i] = s.ID
+	}
+	return ids
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSortStories(t *testing.T) {
+	tests := []struct {
+		sort string
+		want []int
+	}{
+		{sortLatest, []int{5, 2, 6, 4, 3, 7, 1}},
+		{sortOldest, []int{1, 7, 3, 4, 6, 2, 5}},
+		{sortPopularity, []int{7, 5, 2, 4, 3, 1, 6}},
+	}
+	for _, tt := range tests {
+		stories := pageStories()
+		sortStories(stories, tt.sort)
+		if got := storyIDs(stories); !equalIDs(got, tt.want) {
+			t.Errorf("sort=%s: got %v, want %v", tt.sort, got, tt.want)
+		}
+	}
+}
+
+// TestPaginateCursorWalk pages through every sort order with cursors and
+// checks the pages add up to the full sorted list
+func TestPaginateCursorWalk(t *testing.T) {
+	for _, sortBy := range []string{sortLatest, sortOldest, sortPopularity} {
+		for _, limit := range []string{"1", "2", "3", "7", "10"} {
+			stories := pageStories()
+			sortStories(stories, sortBy)
+			want := storyIDs(stories)
+
+			var got []int
+			query := url.Values{"limit": {limit}}
+			for pages := 0; ; pages++ {
+				if pages > len(stories) {
+					t.Fatalf("sort=%s limit=%s: cursor walk did not end", sortBy, limit)
+				}
+				page, ok, err := paginate(stories, sortBy, query)
+				if err != nil || !ok {
+					t.Fatalf("sort=%s limit=%s: paginate = %v, %v", sortBy, limit, ok, err)
+				}
+				if page.Total != len(stories) {
+					t.Errorf("sort=%s limit=%s: total %d, want %d", sortBy, limit, page.Total, len(stories))
+				}
+				got = append(got, storyIDs(page.Stories)...)
+				if page.NextCursor == "" {
+					break
+				}
+				query = url.Values{"limit": {limit}, "cursor": {page.NextCursor}}
+			}
+			if !equalIDs(got, want) {
+				t.Errorf("sort=%s limit=%s: walked %v, want %v", sortBy, limit, got, want)
+			}
+		}
+	}
+}
+
+// A cursor stays valid when stories are added or removed before it
+func TestPaginateCursorStable(t *testing.T) {
+	stories := pageStories()
+	sortStories(stories, sortLatest)
+	page, _, err := paginate(stories, sortLatest, url.Values{"limit": {"3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Drop the first story and add a newer one: the next page is unaffected
+	changed := append([]*Story{{ID: 8, Time: 500}}, stories[1:]...)
+	next, _, err := paginate(changed, sortLatest, url.Values{"limit": {"3"}, "cursor": {page.NextCursor}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := storyIDs(next.Stories), []int{4, 3, 7}; !equalIDs(got, want) {
+		t.Errorf("next page %v, want %v", got, want)
+	}
+}
+
+func TestPaginateOffset(t *testing.T) {
+	stories := pageStories()
+	sortStories(stories, sortOldest)
+
+	page, _, err := paginate(stories, sortOldest, url.Values{"offset": {"5"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Limit != defaultPageLimit || page.Offset != 5 || page.NextCursor != "" {
+		t.Errorf("page = %+v", page)
+	}
+	if got, want := storyIDs(page.Stories), []int{2, 5}; !equalIDs(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	page, _, err = paginate(stories, sortOldest, url.Values{"offset": {"50"}})
+	if err != nil || len(page.Stories) != 0 {
+		t.Errorf("offset past the end: %v, %v", page, err)
+	}
+}
+
+func TestPaginateParams(t *testing.T) {
+	stories := pageStories()
+	sortStories(stories, sortLatest)
+
+	if page, ok, err := paginate(stories, sortLatest, url.Values{}); page != nil || ok || err != nil {
+		t.Errorf("no parameters: %v, %v, %v; want the legacy response", page, ok, err)
+	}
+
+	popular, _, err := paginate(stories, sortPopularity, url.Values{"limit": {"1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := []url.Values{
+		{"limit": {"0"}},
+		{"limit": {"501"}},
+		{"limit": {"ten"}},
+		{"offset": {"-1"}},
+		{"cursor": {"not a cursor!"}},
+		{"cursor": {popular.NextCursor}}, // issued for another sort
+		{"offset": {"1"}, "cursor": {popular.NextCursor}},
+	}
+	for _, query := range bad {
+		if _, ok, err := paginate(stories, sortLatest, query); err == nil || !ok {
+			t.Errorf("%v: got %v, %v; want an error", query, ok, err)
+		}
+	}
+}
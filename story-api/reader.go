@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// messageReader is what the consumer needs from Kafka: a *kafka.Reader in a
+// consumer group, or a partitionsReader without one
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Backoff between attempts to look up the topic's partitions
+const (
+	lookupBackoffMin = time.Second
+	lookupBackoffMax = 30 * time.Second
+)
+
+// partitionsReader reads every partition of a topic from the first offset
+// without a consumer group, merging them into one stream. Stories are keyed
+// by ID, so all events of a story are on one partition and stay in order.
+// Partitions are looked up on the first fetch; partitions added to the topic
+// later are picked up on the next start. Nothing is committed.
+type partitionsReader struct {
+	cfg    KafkaConfig
+	dialer *kafka.Dialer
+
+	start    sync.Once
+	messages chan kafka.Message
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	readers []*kafka.Reader
+}
+
+func newPartitionsReader(cfg KafkaConfig, dialer *kafka.Dialer) *partitionsReader {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &partitionsReader{
+		cfg:      cfg,
+		dialer:   dialer,
+		messages: make(chan kafka.Message),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// FetchMessage returns the next message from any partition
+func (p *partitionsReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	p.start.Do(func() {
+		p.wg.Add(1)
+		go p.run()
+	})
+
+	select {
+	case msg := <-p.messages:
+		return msg, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	case <-p.ctx.Done():
+		return kafka.Message{}, context.Canceled
+	}
+}
+
+// CommitMessages does nothing: without a group there is nowhere to commit to
+func (p *partitionsReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	return nil
+}
+
+func (p *partitionsReader) Close() error {
+	p.cancel()
+	p.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var firstErr error
+	for _, r := range p.readers {
+		if err := r.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// run looks up the partitions, retrying until it succeeds or the reader is
+// closed, and starts a reader per partition
+func (p *partitionsReader) run() {
+	defer p.wg.Done()
+
+	backoff := lookupBackoffMin
+	var partitions []kafka.Partition
+	for {
+		var err error
+		partitions, err = p.dialer.LookupPartitions(p.ctx, "tcp", p.cfg.Broker, p.cfg.Topic)
+		if err == nil && len(partitions) > 0 {
+			break
+		}
+		if err == nil {
+			err = fmt.Errorf("topic has no partitions")
+		}
+		if p.ctx.Err() != nil {
+			return
+		}
+		fmt.Printf("[ERROR] Failed to look up partitions of %s, retrying in %v: %v\n", p.cfg.Topic, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-p.ctx.Done():
+			return
+		}
+		backoff = min(2*backoff, lookupBackoffMax)
+	}
+	fmt.Printf("[DEBUG] Reading %d partitions of %s from the start\n", len(partitions), p.cfg.Topic)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, partition := range partitions {
+		// Without a group a reader starts at the first offset
+		r := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:   []string{p.cfg.Broker},
+			Topic:     p.cfg.Topic,
+			Partition: partition.ID,
+			Dialer:    p.dialer,
+		})
+		p.readers = append(p.readers, r)
+		p.wg.Add(1)
+		go p.readPartition(r)
+	}
+}
+
+func (p *partitionsReader) readPartition(r *kafka.Reader) {
+	defer p.wg.Done()
+	for {
+		msg, err := r.FetchMessage(p.ctx)
+		if err != nil {
+			if p.ctx.Err() != nil {
+				return
+			}
+			fmt.Printf("[ERROR] Failed to fetch message from partition %d: %v\n", r.Config().Partition, err)
+			continue
+		}
+
+		select {
+		case p.messages <- msg:
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const defaultRetentionIntervalSeconds = 60
+
+// Eviction reasons, used as keys of the retention_evicted_total metric
+const (
+	evictMaxAge     = "max_age"
+	evictLowScore   = "low_score"
+	evictMaxStories = "max_stories"
+)
+
+// Retention metrics, served at /debug/vars
+var (
+	retentionEvicted = expvar.NewMap("retention_evicted_total")
+	storedStories    = expvar.NewInt("stored_stories")
+)
+
+// RetentionConfig bounds what an instance keeps. Zero values disable a rule.
+type RetentionConfig struct {
+	// MaxAgeHours evicts stories whose Time is older than this
+	MaxAgeHours int `yaml:"max_age_hours"`
+	// MaxStories keeps only the newest stories by Time
+	MaxStories int `yaml:"max_stories"`
+	// MinScore evicts stories still below this score MinScoreAfterHours
+	// after they were posted
+	MinScore           int `yaml:"min_score"`
+	MinScoreAfterHours int `yaml:"min_score_after_hours"`
+	// IntervalSeconds is how often the janitor runs (default 60)
+	IntervalSeconds int `yaml:"interval_seconds"`
+}
+
+func (c RetentionConfig) enabled() bool {
+	return c.MaxAgeHours > 0 || c.MaxStories > 0 || c.MinScore > 0
+}
+
+// runJanitor enforces the retention policy on startup and then on its interval
+// until shutdown
+func (s *Server) runJanitor() {
+	cfg := s.config.Retention
+	interval := cfg.IntervalSeconds
+	if interval <= 0 {
+		interval = defaultRetentionIntervalSeconds
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		if err := s.enforceRetention(time.Now()); err != nil {
+			fmt.Printf("[ERROR] Retention: %v\n", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// enforceRetention evicts the stories the retention policy no longer allows.
+// Age rules skip stories without a time.
+func (s *Server) enforceRetention(now time.Time) error {
+	cfg := s.config.Retention
+	stories, err := s.store.GetAllStories()
+	if err != nil {
+		return err
+	}
+
+	evicted := make(map[string]int)
+	evict := func(story *Story, reason string) error {
+		removed, err := s.store.RemoveStory(story.ID)
+		if err != nil {
+			return err
+		}
+		if removed {
+			evicted[reason]++
+			retentionEvicted.Add(reason, 1)
+		}
+		return nil
+	}
+
+	maxAgeCutoff := now.Add(-time.Duration(cfg.MaxAgeHours) * time.Hour).Unix()
+	minScoreCutoff := now.Add(-time.Duration(cfg.MinScoreAfterHours) * time.Hour).Unix()
+
+	kept := make([]*Story, 0, len(stories))
+	for _, story := range stories {
+		switch {
+		case cfg.MaxAgeHours > 0 && story.Time > 0 && story.Time < maxAgeCutoff:
+			err = evict(story, evictMaxAge)
+		case cfg.MinScore > 0 && story.Score < cfg.MinScore && story.Time > 0 && story.Time <= minScoreCutoff:
+			err = evict(story, evictLowScore)
+		default:
+			kept = append(kept, story)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.MaxStories > 0 && len(kept) > cfg.MaxStories {
+		// Newest first; the tail beyond the limit is evicted
+		sort.Slice(kept, func(i, j int) bool {
+			if kept[i].Time != kept[j].Time {
+				return kept[i].Time > kept[j].Time
+			}
+			return kept[i].ID > kept[j].ID
+		})
+		for _, story := range kept[cfg.MaxStories:] {
+			if err := evict(story, evictMaxStories); err != nil {
+				return err
+			}
+		}
+		kept = kept[:cfg.MaxStories]
+	}
+
+	storedStories.Set(int64(len(kept)))
+	if total := evicted[evictMaxAge] + evicted[evictLowScore] + evicted[evictMaxStories]; total > 0 {
+		fmt.Printf("[EVICTED] %d stories (max age: %d, low score: %d, max stories: %d), %d remaining\n",
+			total, evicted[evictMaxAge], evicted[evictLowScore], evicted[evictMaxStories], len(kept))
+	}
+	return nil
+}
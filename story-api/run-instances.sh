@@ -1,6 +1,7 @@
 #!/bin/bash
 
 # Script to run multiple story-api instances with different filters
+# (config.feeds.yaml serves the same filters as named feeds of one process)
 # Each instance consumes from the same Kafka topic but filters stories differently
 
 set -e
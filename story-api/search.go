@@ -0,0 +1,357 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Indexed fields and their weights in the relevance score
+const (
+	fieldTitle = iota
+	fieldAuthor
+	fieldDomain
+	numFields
+)
+
+var fieldWeights = [numFields]float64{
+	fieldTitle:  3,
+	fieldAuthor: 2,
+	fieldDomain: 1,
+}
+
+// phraseBoost multiplies the score of a phrase match over its separate terms
+const phraseBoost = 2
+
+// termPostings holds the token positions of one term in one story, per field
+type termPostings [numFields][]int
+
+// SearchIndex is an in-memory inverted index over story titles, authors and
+// URL domains. It is safe for concurrent use.
+type SearchIndex struct {
+	mu       sync.RWMutex
+	postings map[string]map[int]*termPostings // term -> story ID -> positions
+	stories  map[int]*Story
+	terms    map[int][]string // story ID -> indexed terms, for removal
+}
+
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		postings: make(map[string]map[int]*termPostings),
+		stories:  make(map[int]*Story),
+		terms:    make(map[int][]string),
+	}
+}
+
+// tokenize lowercases text and splits it into runs of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Add indexes a story, replacing any previous version of it
+func (idx *SearchIndex) Add(story *Story) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(story.ID)
+
+	fields := [numFields][]string{
+		fieldTitle:  tokenize(story.Title),
+		fieldAuthor: tokenize(story.By),
+		fieldDomain: tokenize(storyDomain(story.URL)),
+	}
+	var terms []string
+	for field, tokens := range fields {
+		for pos, token := range tokens {
+			docs := idx.postings[token]
+			if docs == nil {
+				docs = make(map[int]*termPostings)
+				idx.postings[token] = docs
+			}
+			p := docs[story.ID]
+			if p == nil {
+				p = &termPostings{}
+				docs[story.ID] = p
+				terms = append(terms, token)
+			}
+			p[field] = append(p[field], pos)
+		}
+	}
+
+	idx.stories[story.ID] = story
+	idx.terms[story.ID] = terms
+}
+
+// Remove drops a story from the index
+func (idx *SearchIndex) Remove(id int) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+}
+
+func (idx *SearchIndex) remove(id int) {
+	for _, term := range idx.terms[id] {
+		docs := idx.postings[term]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	delete(idx.terms, id)
+	delete(idx.stories, id)
+}
+
+// queryClause is one part of a search query: a term, a prefix (term*) or a
+// quoted phrase. A story must match every clause.
+type queryClause struct {
+	tokens []string // more than one for phrases
+	prefix bool
+}
+
+// parseQuery splits a query into clauses. Quoted text is a phrase and a
+// trailing * makes a term a prefix; an unterminated quote runs to the end.
+func parseQuery(q string) ([]queryClause, error) {
+	var clauses []queryClause
+	for len(q) > 0 {
+		q = strings.TrimLeftFunc(q, unicode.IsSpace)
+		if q == "" {
+			break
+		}
+
+		if q[0] == '"' {
+			end := strings.IndexByte(q[1:], '"')
+			phrase := q[1:]
+			if end >= 0 {
+				phrase, q = q[1:end+1], q[end+2:]
+			} else {
+				q = ""
+			}
+			if tokens := tokenize(phrase); len(tokens) > 0 {
+				clauses = append(clauses, queryClause{tokens: tokens})
+			}
+			continue
+		}
+
+		word := q
+		if end := strings.IndexFunc(q, unicode.IsSpace); end >= 0 {
+			word, q = q[:end], q[end:]
+		} else {
+			q = ""
+		}
+		prefix := strings.HasSuffix(word, "*")
+		tokens := tokenize(word)
+		switch {
+		case len(tokens) == 0:
+		case prefix && len(tokens) == 1:
+			clauses = append(clauses, queryClause{tokens: tokens, prefix: true})
+		default:
+			// Words like "node.js" tokenize to several terms; match them as
+			// a phrase
+			clauses = append(clauses, queryClause{tokens: tokens})
+		}
+	}
+
+	if len(clauses) == 0 {
+		return nil, fmt.Errorf("query has no searchable terms")
+	}
+	return clauses, nil
+}
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// SearchResponse is the GET /search response
+type SearchResponse struct {
+	Version int    `json:"version"`
+	Query   string `json:"query"`
+	// Total is the number of matching stories, across all pages
+	Total   int            `json:"total"`
+	Limit   int            `json:"limit"`
+	Offset  int            `json:"offset,omitempty"`
+	Results []SearchResult `json:"results"`
+}
+
+// SearchResult is a matching story and its relevance score
+type SearchResult struct {
+	Score float64 `json:"score"`
+	Story *Story  `json:"story"`
+}
+
+// Search returns the stories matching every clause of q, most relevant first.
+// Scores add up per-field TF-IDF for each clause; ties go to newer stories.
+func (idx *SearchIndex) Search(q string) ([]SearchResult, error) {
+	clauses, err := parseQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var scores map[int]float64
+	for _, clause := range clauses {
+		clauseScores := idx.scoreClause(clause)
+		if scores == nil {
+			scores = clauseScores
+		} else {
+			for id, score := range scores {
+				if s, ok := clauseScores[id]; ok {
+					scores[id] = score + s
+				} else {
+					delete(scores, id)
+				}
+			}
+		}
+		if len(scores) == 0 {
+			return []SearchResult{}, nil
+		}
+	}
+
+	results := make([]SearchResult, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, SearchResult{Score: math.Round(score*1000) / 1000, Story: idx.stories[id]})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Story.Time != b.Story.Time {
+			return a.Story.Time > b.Story.Time
+		}
+		return a.Story.ID > b.Story.ID
+	})
+	return results, nil
+}
+
+// scoreClause returns the score of every story matching a clause
+func (idx *SearchIndex) scoreClause(clause queryClause) map[int]float64 {
+	scores := make(map[int]float64)
+
+	if clause.prefix {
+		for term, docs := range idx.postings {
+			if strings.HasPrefix(term, clause.tokens[0]) {
+				idx.addTermScores(scores, docs)
+			}
+		}
+		return scores
+	}
+
+	if len(clause.tokens) == 1 {
+		idx.addTermScores(scores, idx.postings[clause.tokens[0]])
+		return scores
+	}
+
+	// Phrase: every term must occur, consecutively within one field
+	first := idx.postings[clause.tokens[0]]
+	for id, p := range first {
+		matches := [numFields]int{}
+		found := false
+		for field := 0; field < numFields; field++ {
+			for _, pos := range p[field] {
+				if idx.phraseAt(clause.tokens[1:], id, field, pos+1) {
+					matches[field]++
+					found = true
+				}
+			}
+		}
+		if !found {
+			continue
+		}
+
+		// Rarest term's IDF approximates the phrase's
+		idf := 0.0
+		for _, token := range clause.tokens {
+			idf = math.Max(idf, idx.idf(len(idx.postings[token])))
+		}
+		for field, tf := range matches {
+			scores[id] += phraseBoost * fieldWeights[field] * tfWeight(tf) * idf
+		}
+	}
+	return scores
+}
+
+// phraseAt reports whether tokens occur in order starting at pos
+func (idx *SearchIndex) phraseAt(tokens []string, id, field, pos int) bool {
+	for i, token := range tokens {
+		p := idx.postings[token][id]
+		if p == nil || !containsInt(p[field], pos+i) {
+			return false
+		}
+	}
+	return true
+}
+
+func (idx *SearchIndex) addTermScores(scores map[int]float64, docs map[int]*termPostings) {
+	idf := idx.idf(len(docs))
+	for id, p := range docs {
+		score := 0.0
+		for field, positions := range p {
+			score += fieldWeights[field] * tfWeight(len(positions))
+		}
+		// A story matching several prefix expansions keeps its best one
+		scores[id] = math.Max(scores[id], score*idf)
+	}
+}
+
+func (idx *SearchIndex) idf(docFreq int) float64 {
+	return math.Log(1 + float64(len(idx.stories))/float64(docFreq+1))
+}
+
+// tfWeight dampens repeated occurrences of a term
+func tfWeight(tf int) float64 {
+	if tf == 0 {
+		return 0
+	}
+	return 1 + math.Log(float64(tf))
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// indexedStore keeps a search index in step with the store it wraps
+type indexedStore struct {
+	StoryStore
+	index *SearchIndex
+}
+
+// newIndexedStore wraps store and indexes the stories it already holds
+func newIndexedStore(store StoryStore, index *SearchIndex) (*indexedStore, error) {
+	stories, err := store.GetAllStories()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stories for search index: %w", err)
+	}
+	for _, story := range stories {
+		index.Add(story)
+	}
+	return &indexedStore{StoryStore: store, index: index}, nil
+}
+
+func (s *indexedStore) AddStory(story *Story) (bool, error) {
+	replaced, err := s.StoryStore.AddStory(story)
+	if err == nil {
+		s.index.Add(story)
+	}
+	return replaced, err
+}
+
+func (s *indexedStore) RemoveStory(id int) (bool, error) {
+	removed, err := s.StoryStore.RemoveStory(id)
+	if err == nil {
+		s.index.Remove(id)
+	}
+	return removed, err
+}
@@ -0,0 +1,197 @@
+package main
+
+import "testing"
+
+func searchIDs(t *testing.T, idx *SearchIndex, q string) []int {
+	t.Helper()
+	results, err := idx.Search(q)
+	if err != nil {
+		t.Fatalf("Search(%q): %v", q, err)
+	}
+	ids := make([]int, len(results))
+	for i, r := range results {
+		ids[i] = r.Story.ID
+	}
+	return ids
+}
+
+func newTestIndex(stories ...*Story) *SearchIndex {
+	idx := NewSearchIndex()
+	for _, s := range stories {
+		idx.Add(s)
+	}
+	return idx
+}
+
+func TestTokenize(t *testing.T) {
+	got := tokenize("Show HN: Node.js 22 — Zürich's café!")
+	want := []string{"show", "hn", "node", "js", "22", "zürich", "s", "café"}
+	if len(got) != len(want) {
+		t.Fatalf("tokenize = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("tokenize = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestSearchFieldWeights(t *testing.T) {
+	idx := newTestIndex(
+		&Story{ID: 1, Title: "Notes", URL: "https://rust.example.com/", By: "bob", Time: 3},
+		&Story{ID: 2, Title: "Writing Rust at work", By: "alice", Time: 1},
+		&Story{ID: 3, Title: "Notes", By: "rust", Time: 2},
+		&Story{ID: 4, Title: "Unrelated", By: "carol", Time: 4},
+	)
+	// Title beats author beats domain, whatever the story's age
+	if got, want := searchIDs(t, idx, "rust"), []int{2, 3, 1}; !equalIDs(got, want) {
+		t.Errorf("rust: got %v, want %v", got, want)
+	}
+}
+
+func TestSearchAllClausesMustMatch(t *testing.T) {
+	idx := newTestIndex(
+		&Story{ID: 1, Title: "Rust and Kafka", Time: 1},
+		&Story{ID: 2, Title: "Rust only", Time: 2},
+		&Story{ID: 3, Title: "Kafka only", Time: 3},
+	)
+	if got, want := searchIDs(t, idx, "RUST kafka"), []int{1}; !equalIDs(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := searchIDs(t, idx, "rust zig"); len(got) != 0 {
+		t.Errorf("rust zig: got %v, want no results", got)
+	}
+}
+
+func TestSearchPhrase(t *testing.T) {
+	idx := newTestIndex(
+		&Story{ID: 1, Title: "Show HN: My project", Time: 1},
+		&Story{ID: 2, Title: "HN show and tell", Time: 2},
+		&Story{ID: 3, Title: "Show the HN crowd", Time: 3},
+		&Story{ID: 4, Title: "What's new in Node.js", Time: 4},
+		&Story{ID: 5, Title: "Node and JS", Time: 5},
+	)
+	if got, want := searchIDs(t, idx, `"show hn"`), []int{1}; !equalIDs(got, want) {
+		t.Errorf(`"show hn": got %v, want %v`, got, want)
+	}
+	// Punctuated words are phrases of their parts
+	if got, want := searchIDs(t, idx, "node.js"), []int{4}; !equalIDs(got, want) {
+		t.Errorf("node.js: got %v, want %v", got, want)
+	}
+	// An unterminated quote runs to the end of the query
+	if got, want := searchIDs(t, idx, `"show hn`), []int{1}; !equalIDs(got, want) {
+		t.Errorf(`"show hn: got %v, want %v`, got, want)
+	}
+}
+
+func TestSearchPhraseBoost(t *testing.T) {
+	idx := newTestIndex(
+		&Story{ID: 1, Title: "Machine learning", Time: 1},
+		&Story{ID: 2, Title: "Learning about machine shops", Time: 2},
+	)
+	results, err := idx.Search(`"machine learning"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Story.ID != 1 {
+		t.Fatalf("phrase: got %v", results)
+	}
+
+	terms, err := idx.Search("machine learning")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var termScore float64
+	for _, r := range terms {
+		if r.Story.ID == 1 {
+			termScore = r.Score
+		}
+	}
+	if termScore == 0 || results[0].Score < termScore {
+		t.Errorf("phrase score %v below the separate terms' %v", results[0].Score, termScore)
+	}
+}
+
+func TestSearchPrefix(t *testing.T) {
+	idx := newTestIndex(
+		&Story{ID: 1, Title: "Kubernetes in production", Time: 1},
+		&Story{ID: 2, Title: "Kubelet internals", Time: 2},
+		&Story{ID: 3, Title: "Cube sat launch", Time: 3},
+	)
+	if got, want := searchIDs(t, idx, "kube*"), []int{2, 1}; !equalIDs(got, want) {
+		t.Errorf("kube*: got %v, want %v", got, want)
+	}
+	if got := searchIDs(t, idx, "kube"); len(got) != 0 {
+		t.Errorf("kube without *: got %v, want no results", got)
+	}
+}
+
+func TestSearchTiesGoToNewerStories(t *testing.T) {
+	idx := newTestIndex(
+		&Story{ID: 1, Title: "Zig release", Time: 10},
+		&Story{ID: 2, Title: "Zig release", Time: 30},
+		&Story{ID: 3, Title: "Zig release", Time: 20},
+	)
+	if got, want := searchIDs(t, idx, "zig"), []int{2, 3, 1}; !equalIDs(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSearchIndexUpdates(t *testing.T) {
+	idx := newTestIndex(
+		&Story{ID: 1, Title: "Rust compiler internals", Time: 1},
+		&Story{ID: 2, Title: "Rust in the kernel", Time: 2},
+	)
+
+	// Re-adding a story replaces its terms
+	idx.Add(&Story{ID: 1, Title: "Zig compiler internals", Time: 1})
+	if got, want := searchIDs(t, idx, "rust"), []int{2}; !equalIDs(got, want) {
+		t.Errorf("rust after retitle: got %v, want %v", got, want)
+	}
+	if got, want := searchIDs(t, idx, "zig"), []int{1}; !equalIDs(got, want) {
+		t.Errorf("zig after retitle: got %v, want %v", got, want)
+	}
+
+	idx.Remove(2)
+	if got := searchIDs(t, idx, "rust"); len(got) != 0 {
+		t.Errorf("rust after remove: got %v, want no results", got)
+	}
+	if _, ok := idx.postings["kernel"]; ok {
+		t.Error("removed story's terms are still in the index")
+	}
+	if len(idx.stories) != 1 || len(idx.terms) != 1 {
+		t.Errorf("index holds %d stories and %d term lists, want 1", len(idx.stories), len(idx.terms))
+	}
+}
+
+func TestIndexedStoreKeepsIndexInStep(t *testing.T) {
+	base := NewMemoryStore()
+	base.AddStory(&Story{ID: 1, Title: "Already stored", Time: 1})
+
+	idx := NewSearchIndex()
+	store, err := newIndexedStore(base, idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := searchIDs(t, idx, "stored"), []int{1}; !equalIDs(got, want) {
+		t.Errorf("existing stories: got %v, want %v", got, want)
+	}
+
+	store.AddStory(&Story{ID: 2, Title: "Newly stored", Time: 2})
+	if got, want := searchIDs(t, idx, "stored"), []int{2, 1}; !equalIDs(got, want) {
+		t.Errorf("after add: got %v, want %v", got, want)
+	}
+	store.RemoveStory(1)
+	if got, want := searchIDs(t, idx, "stored"), []int{2}; !equalIDs(got, want) {
+		t.Errorf("after remove: got %v, want %v", got, want)
+	}
+}
+
+func TestSearchEmptyQuery(t *testing.T) {
+	idx := newTestIndex(&Story{ID: 1, Title: "Anything"})
+	for _, q := range []string{"", "   ", `""`, "*", "!!"} {
+		if _, err := idx.Search(q); err == nil {
+			t.Errorf("Search(%q) succeeded, want an error", q)
+		}
+	}
+}
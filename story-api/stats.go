@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// recentRejectedSize is how many rejected stories GET /stats/filter keeps
+const recentRejectedSize = 100
+
+// FilterStats counts what happened to consumed messages since startup. It is
+// safe for concurrent use.
+type FilterStats struct {
+	mu         sync.Mutex
+	since      time.Time
+	consumed   int64
+	stored     int64
+	updated    int64
+	filtered   int64
+	removed    int64
+	deleted    int64
+	errors     int64
+	rejectedBy map[string]map[string]int64 // feed -> clause -> count
+	recent     []RejectedStory             // ring buffer, oldest at next once full
+	next       int
+}
+
+// RejectedStory is a story no feed wanted, with the clause each feed
+// rejected it on
+type RejectedStory struct {
+	At         time.Time         `json:"at"`
+	Story      *Story            `json:"story"`
+	RejectedBy map[string]string `json:"rejected_by"`
+}
+
+// FilterStatsResponse is the GET /stats/filter response
+type FilterStatsResponse struct {
+	Since    time.Time `json:"since"`
+	Consumed int64     `json:"consumed"`
+	// Stored counts new stories; Updated counts new versions of stored ones
+	Stored  int64 `json:"stored"`
+	Updated int64 `json:"updated"`
+	// Filtered counts stories no feed wanted; Removed is the part of them
+	// that dropped a stored copy because the story stopped matching
+	Filtered int64 `json:"filtered"`
+	Removed  int64 `json:"removed"`
+	Deleted  int64 `json:"deleted"`
+	// Errors counts messages that failed to decode and failed attempts to
+	// store a message, which are retried
+	Errors int64 `json:"errors"`
+	// RejectedBy counts, per feed, the filter clause that rejected each
+	// filtered story
+	RejectedBy map[string]map[string]int64 `json:"rejected_by"`
+	// RecentRejected holds the latest filtered stories, newest first
+	RecentRejected []RejectedStory `json:"recent_rejected"`
+}
+
+func NewFilterStats() *FilterStats {
+	return &FilterStats{
+		since:      time.Now(),
+		rejectedBy: make(map[string]map[string]int64),
+	}
+}
+
+// consumedMessage counts a message read from Kafka, whatever its outcome
+func (st *FilterStats) consumedMessage() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.consumed++
+}
+
+// storedStory counts a story added to the store
+func (st *FilterStats) storedStory(replaced bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if replaced {
+		st.updated++
+	} else {
+		st.stored++
+	}
+}
+
+func (st *FilterStats) deletedStory() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.deleted++
+}
+
+func (st *FilterStats) failedMessage() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.errors++
+}
+
+// rejectedStory counts a story no feed wanted and keeps it as a sample.
+// rejectedBy maps each feed to the clause that rejected the story.
+func (st *FilterStats) rejectedStory(story *Story, rejectedBy map[string]string, removed bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.filtered++
+	if removed {
+		st.removed++
+	}
+	for feed, clause := range rejectedBy {
+		counts := st.rejectedBy[feed]
+		if counts == nil {
+			counts = make(map[string]int64)
+			st.rejectedBy[feed] = counts
+		}
+		counts[clause]++
+	}
+
+	sample := RejectedStory{At: time.Now(), Story: story, RejectedBy: rejectedBy}
+	if len(st.recent) < recentRejectedSize {
+		st.recent = append(st.recent, sample)
+	} else {
+		st.recent[st.next] = sample
+	}
+	st.next = (st.next + 1) % recentRejectedSize
+}
+
+// snapshot copies the counters and up to limit recent samples, newest first
+func (st *FilterStats) snapshot(limit int) FilterStatsResponse {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	resp := FilterStatsResponse{
+		Since:          st.since,
+		Consumed:       st.consumed,
+		Stored:         st.stored,
+		Updated:        st.updated,
+		Filtered:       st.filtered,
+		Removed:        st.removed,
+		Deleted:        st.deleted,
+		Errors:         st.errors,
+		RejectedBy:     make(map[string]map[string]int64, len(st.rejectedBy)),
+		RecentRejected: make([]RejectedStory, 0, min(limit, len(st.recent))),
+	}
+	for feed, counts := range st.rejectedBy {
+		c := make(map[string]int64, len(counts))
+		for clause, n := range counts {
+			c[clause] = n
+		}
+		resp.RejectedBy[feed] = c
+	}
+	for i := 1; i <= len(st.recent) && len(resp.RecentRejected) < limit; i++ {
+		j := (st.next - i + len(st.recent)) % len(st.recent)
+		resp.RecentRejected = append(resp.RecentRejected, st.recent[j])
+	}
+	return resp
+}
+
+// handleFilterStats handles GET /stats/filter. ?samples=N limits the recent
+// rejected stories returned (default and maximum 100, 0 for none).
+func (s *Server) handleFilterStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	samples := recentRejectedSize
+	if v := r.URL.Query().Get("samples"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > recentRejectedSize {
+			http.Error(w, fmt.Sprintf("samples must be between 0 and %d", recentRejectedSize), http.StatusBadRequest)
+			return
+		}
+		samples = n
+	}
+	writeJSON(w, s.stats.snapshot(samples))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// StoreConfig selects where stories are kept
+type StoreConfig struct {
+	Type string `yaml:"type"` // memory (default) or bolt
+	Path string `yaml:"path"` // bolt: database file, default stories.db
+}
+
+const defaultBoltStorePath = "stories.db"
+
+// StoryStore holds the stories an instance serves. Implementations must be
+// safe for concurrent use.
+type StoryStore interface {
+	// AddStory inserts or replaces a story, reporting whether it replaced one
+	AddStory(story *Story) (bool, error)
+	// RemoveStory deletes a story, reporting whether it was stored
+	RemoveStory(id int) (bool, error)
+	GetAllStories() ([]*Story, error)
+	Close() error
+}
+
+// newStoryStore creates the store selected in the config
+// persistent reports whether stories outlive the process
+func (c StoreConfig) persistent() bool {
+	return c.Type != "" && c.Type != "memory"
+}
+
+func newStoryStore(cfg StoreConfig) (StoryStore, error) {
+	switch cfg.Type {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "bolt":
+		path := cfg.Path
+		if path == "" {
+			path = defaultBoltStorePath
+		}
+		return NewBoltStore(path)
+	default:
+		return nil, fmt.Errorf("unknown store type %q (want memory or bolt)", cfg.Type)
+	}
+}
+
+// MemoryStore keeps stories in a map; everything is lost on restart
+type MemoryStore struct {
+	mu      sync.RWMutex
+	stories map[int]*Story // ID -> Story
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{stories: make(map[int]*Story)}
+}
+
+func (s *MemoryStore) AddStory(story *Story) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, existed := s.stories[story.ID]
+	s.stories[story.ID] = story
+	return existed, nil
+}
+
+func (s *MemoryStore) RemoveStory(id int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, existed := s.stories[id]
+	delete(s.stories, id)
+	return existed, nil
+}
+
+func (s *MemoryStore) GetAllStories() ([]*Story, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stories := make([]*Story, 0, len(s.stories))
+	for _, story := range s.stories {
+		stories = append(stories, story)
+	}
+	return stories, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}